@@ -113,7 +113,7 @@ func (c *Client) Do(req *http.Request, v interface{}) (*http.Response, error) {
 
 	if v != nil {
 		if w, ok := v.(io.Writer); ok {
-			io.Copy(w, resp.Body)
+			_, err = io.Copy(w, resp.Body)
 		} else {
 			if r, _ := regexp.MatchString("(plain|xml|xhtml)", resp.Header.Get("Content-Type")); r {
 				if err := xml.NewDecoder(resp.Body).Decode(v); err == io.EOF {
@@ -147,11 +147,11 @@ func Params(m0 map[string]interface{}) (m map[string]string) {
 			}
 		case reflect.String:
 			if val.Len() != 0 {
-				m[k] = v.(string)
+				m[k] = val.String()
 			}
 		case reflect.Int:
-			if v != 0 {
-				m[k] = strconv.FormatInt(int64(v.(int)), 10)
+			if val.Int() != 0 {
+				m[k] = strconv.FormatInt(val.Int(), 10)
 			}
 		case reflect.Array, reflect.Slice:
 			b, _ := json.Marshal(val.Interface())
@@ -3,16 +3,12 @@ package ali
 import (
 	"bytes"
 	"crypto"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/base64"
-	"encoding/pem"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/url"
-	"reflect"
 	"sort"
 	"strings"
 
@@ -24,19 +20,40 @@ const orderURL = "https://mapi.alipay.com/gateway.do"
 
 // sign methods
 const (
-	MD5 = "MD5"
-	RSA = "RSA"
+	MD5  = "MD5"
+	RSA  = "RSA"
+	RSA2 = "RSA2"
 )
 
+// ErrNotifyID is returned by VerifyNotifyID when Alipay doesn't confirm
+// the notify_id.
+var ErrNotifyID = errors.New("ali: notify_id not verified")
+
 // Ali ...
 type Ali struct {
-	client *apikit.Client
+	client  *apikit.Client
+	gateway string
+}
+
+// Option configures an Ali.
+type Option func(*Ali)
+
+// Gateway overrides the gateway URL, which defaults to
+// https://mapi.alipay.com/gateway.do.
+func Gateway(u string) Option {
+	return func(a *Ali) {
+		a.gateway = u
+	}
 }
 
 // New makes an ali ...
-func New(httpClient *http.Client) *Ali {
+func New(httpClient *http.Client, opts ...Option) *Ali {
 	c := apikit.NewClient(httpClient)
-	return &Ali{client: c}
+	a := &Ali{client: c, gateway: orderURL}
+	for _, o := range opts {
+		o(a)
+	}
+	return a
 }
 
 // Req ...
@@ -81,10 +98,6 @@ func sortedParams(m map[string]string) bytes.Buffer {
 	return buf
 }
 
-func removeQuote(b []byte) []byte {
-	return bytes.Replace(b, []byte(`"`), []byte(``), -1)
-}
-
 func removeKeys(m map[string]string, keys ...string) map[string]string {
 	for _, k := range keys {
 		if _, ok := m[k]; ok {
@@ -94,69 +107,91 @@ func removeKeys(m map[string]string, keys ...string) map[string]string {
 	return m
 }
 
-// Sign ...
-func (a *Ali) Sign(s interface{}, secretKey []byte) (b []byte) {
+// Sign signs s by its sign_type, secretKey is the MD5 key for MD5, or the
+// private key for RSA (SHA1) and RSA2 (SHA256), which is PEM or bare base64
+// encoded PKCS#1 or PKCS#8.
+//
+// The format differs from older versions, which returned only the sign:
+// RSA signs the sorted params as k=v instead of k="v", and MD5 returns the
+// lowercase hex digest instead of the raw bytes. Legacy mobile app orders
+// signing the quoted params are built by MobileOrderString.
+func (a *Ali) Sign(s interface{}, secretKey []byte) ([]byte, error) {
 	m := apikit.Params(structs.Map(s))
 	st := m["sign_type"]
-	buf := sortedParams(removeKeys(m, "sign", "sign_type"))
+	b := joinParams(removeKeys(m, "sign", "sign_type"))
 	switch st {
-	case RSA:
-		p, _ := pem.Decode([]byte(secretKey))
-		if p == nil {
-			panic("Secret key broken!")
+	case RSA, RSA2:
+		key, err := parsePrivateKey(secretKey)
+		if err != nil {
+			return nil, err
 		}
-		key, err := x509.ParsePKCS8PrivateKey(p.Bytes)
+		sig, err := signRSA(key, signHash(st), b)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		h := crypto.Hash.New(crypto.SHA1)
-		h.Write(buf.Bytes())
-		sum := h.Sum(nil)
-		sig, _ := rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA1, sum)
-		return []byte(base64.StdEncoding.EncodeToString(sig))
+		return []byte(sig), nil
 	case MD5:
-		buf.WriteString(string(secretKey))
-		h := crypto.Hash.New(crypto.MD5)
-		h.Write(removeQuote(buf.Bytes()))
-		return h.Sum(nil)
+		sum := md5.Sum(append(b, secretKey...))
+		return []byte(hex.EncodeToString(sum[:])), nil
 	}
-	return
+	return nil, fmt.Errorf("ali: unsupported sign_type %q", st)
 }
 
-// VerifyNotifyID ...
-func (a *Ali) VerifyNotifyID(partner, notifyID string) bool {
-	q := fmt.Sprintf("service=notify_verify&partner=%s&notify_id=%s", partner, notifyID)
-	resp, err := http.Get(strings.Join([]string{orderURL, "?", q}, ""))
-	if err != nil {
-		return false
+func signHash(signType string) crypto.Hash {
+	if signType == RSA2 {
+		return crypto.SHA256
 	}
-	defer resp.Body.Close()
-	b, err := ioutil.ReadAll(resp.Body)
-	return err != nil && reflect.DeepEqual(b, []byte(`true`))
+	return crypto.SHA1
 }
 
-// Verify for RSA sign.
-func (a *Ali) Verify(publicKey, sign []byte, req *NotifyReq) error {
-	p, _ := pem.Decode(publicKey)
-	if p == nil {
-		panic("Public key broken!")
+// VerifyNotifyID asks Alipay whether notifyID was sent by it, it returns
+// ErrNotifyID if not, or the error of the request.
+func (a *Ali) VerifyNotifyID(partner, notifyID string) error {
+	u, err := url.Parse(a.gateway)
+	if err != nil {
+		return err
+	}
+	u.RawQuery = url.Values{
+		"service":   {"notify_verify"},
+		"partner":   {partner},
+		"notify_id": {notifyID},
+	}.Encode()
+	req, err := a.client.NewRequest("GET", u.String(), struct{}{})
+	if err != nil {
+		return err
 	}
-	pub, err := x509.ParsePKIXPublicKey(p.Bytes)
+	var buf bytes.Buffer
+	resp, err := a.client.Do(req, &buf)
 	if err != nil {
 		return err
 	}
-	h := crypto.Hash.New(crypto.SHA1)
-	m := apikit.Params(structs.Map(req))
-	b := sortedParams(removeKeys(m, "sign", "sign_type"))
-	h.Write(removeQuote(b.Bytes()))
-	sum := h.Sum(nil)
-	if sign, err = base64.StdEncoding.DecodeString(string(sign)); err != nil {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ali: notify_verify: %s", resp.Status)
+	}
+	if strings.TrimSpace(buf.String()) != "true" {
+		return ErrNotifyID
+	}
+	return nil
+}
+
+// Verify verifies the RSA or RSA2 sign of req over req.Params if set, or
+// its fields otherwise. publicKey is PEM or bare base64 encoded.
+func (a *Ali) Verify(publicKey, sign []byte, req *NotifyReq) error {
+	pub, err := parsePublicKey(publicKey)
+	if err != nil {
 		return err
 	}
-	return rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA1, sum, sign)
+	m := req.Params
+	if m == nil {
+		m = apikit.Params(structs.Map(req))
+	}
+	b := joinParams(removeKeys(copyParams(m), "sign", "sign_type"))
+	return verifyRSA(pub, signHash(req.SignType), b, string(sign))
 }
 
 // EncodedQuery ...
+//
+// Deprecated: it doesn't match any SDK format, use MobileOrderString.
 func (a *Ali) EncodedQuery(s interface{}) []byte {
 	m := apikit.Params(structs.Map(s))
 	m["sign"] = url.QueryEscape(m["sign"])
@@ -166,7 +201,7 @@ func (a *Ali) EncodedQuery(s interface{}) []byte {
 
 // PayURL ...
 func (a *Ali) PayURL(s interface{}) string {
-	u, err := url.Parse(orderURL)
+	u, err := url.Parse(a.gateway)
 	if err != nil {
 		panic(err)
 	}
@@ -209,4 +244,20 @@ type NotifyReq struct {
 	UseCoupon        string `structs:"use_coupon" form:"use_coupon" json:"use_coupon"`
 	ExtraCommonParam string `structs:"extra_common_param" form:"extra_common_param" json:"extra_common_param"`
 	BusinessScene    string `structs:"business_scene" form:"business_scene" json:"business_scene"`
+	// open platform fields
+	AppID          string `structs:"app_id" form:"app_id" json:"app_id"`
+	AuthAppID      string `structs:"auth_app_id" form:"auth_app_id" json:"auth_app_id"`
+	Charset        string `structs:"charset" form:"charset" json:"charset"`
+	Version        string `structs:"version" form:"version" json:"version"`
+	OutBizNo       string `structs:"out_biz_no" form:"out_biz_no" json:"out_biz_no"`
+	BuyerLogonID   string `structs:"buyer_logon_id" form:"buyer_logon_id" json:"buyer_logon_id"`
+	TotalAmount    string `structs:"total_amount" form:"total_amount" json:"total_amount"`
+	ReceiptAmount  string `structs:"receipt_amount" form:"receipt_amount" json:"receipt_amount"`
+	InvoiceAmount  string `structs:"invoice_amount" form:"invoice_amount" json:"invoice_amount"`
+	BuyerPayAmount string `structs:"buyer_pay_amount" form:"buyer_pay_amount" json:"buyer_pay_amount"`
+	PointAmount    string `structs:"point_amount" form:"point_amount" json:"point_amount"`
+	RefundFee      string `structs:"refund_fee" form:"refund_fee" json:"refund_fee"`
+	PassbackParams string `structs:"passback_params" form:"passback_params" json:"passback_params"`
+	// Params holds all the received parameters.
+	Params map[string]string `structs:"-" form:"-" json:"-"`
 }
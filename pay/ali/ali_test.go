@@ -0,0 +1,45 @@
+package ali
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerifyNotifyID(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+		fail   bool
+	}{
+		{http.StatusOK, "true", nil, false},
+		{http.StatusOK, "true\n", nil, false},
+		{http.StatusOK, "false", ErrNotifyID, true},
+		{http.StatusInternalServerError, "true", nil, true},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			if q.Get("service") != "notify_verify" || q.Get("partner") != "p1" || q.Get("notify_id") != "n1" {
+				t.Errorf("query = %v", q)
+			}
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		err := New(nil, Gateway(srv.URL)).VerifyNotifyID("p1", "n1")
+		srv.Close()
+		if (err != nil) != tt.fail || tt.want != nil && err != tt.want || tt.want == nil && err == ErrNotifyID {
+			t.Errorf("%d %q: got %v", tt.status, tt.body, err)
+		}
+	}
+}
+
+func TestVerifyNotifyIDNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	err := New(nil, Gateway(srv.URL)).VerifyNotifyID("p1", "n1")
+	if err == nil || err == ErrNotifyID {
+		t.Errorf("got %v, want a request error", err)
+	}
+}
@@ -0,0 +1,420 @@
+package ali
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/douglarek/apikit/pay/internal/bill"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+const methodBillURL = "alipay.data.dataservice.bill.downloadurl.query"
+
+// bill types
+const (
+	BillTrade        = "trade"
+	BillSignCustomer = "signcustomer"
+)
+
+// BillURLReq queries the bill of BillDate, which is yyyy-MM-dd for a daily
+// bill or yyyy-MM for a monthly one.
+type BillURLReq struct {
+	BillType string `json:"bill_type"`
+	BillDate string `json:"bill_date"`
+}
+
+// BillURLResp ...
+type BillURLResp struct {
+	RespCommon
+	BillDownloadURL string `json:"bill_download_url"`
+}
+
+// BillURL returns the download URL of the bill zip, which expires in 30
+// seconds.
+func (c *Client) BillURL(r *BillURLReq) (*BillURLResp, error) {
+	res := new(BillURLResp)
+	if err := c.do(methodBillURL, r, "", res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DownloadBill writes the bill zip to dst, which can be opened by OpenBill.
+func (c *Client) DownloadBill(r *BillURLReq, dst io.Writer) error {
+	res, err := c.BillURL(r)
+	if err != nil {
+		return err
+	}
+	req, err := c.client.NewRequest("GET", res.BillDownloadURL, struct{}{})
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req, dst)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("ali: download failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// Bill is a bill zip, it holds a details CSV and a summary CSV, which are
+// named like 2088xxx_20060102_业务明细.csv and 2088xxx_20060102_业务明细(汇总).csv
+// for trade bills, or 账务明细 for signcustomer bills.
+type Bill struct {
+	details *zip.File
+	summary *zip.File
+}
+
+// OpenBill opens the bill zip written by DownloadBill.
+func OpenBill(r io.ReaderAt, size int64) (*Bill, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	b := new(Bill)
+	for _, f := range z.File {
+		// names without the UTF-8 flag are GBK, zip.File.NonUTF8 needs Go 1.10.
+		name := f.Name
+		if f.Flags&0x800 == 0 && !utf8.ValidString(name) {
+			if name, err = simplifiedchinese.GBK.NewDecoder().String(name); err != nil {
+				return nil, err
+			}
+		}
+		if !strings.HasSuffix(name, ".csv") {
+			continue
+		}
+		if strings.Contains(name, "汇总") {
+			b.summary = f
+		} else {
+			b.details = f
+		}
+	}
+	if b.details == nil || b.summary == nil {
+		return nil, errors.New("ali: no bill in zip")
+	}
+	return b, nil
+}
+
+// TradeBill returns a reader of the details of a trade bill, it must be
+// closed after use.
+func (b *Bill) TradeBill() (*TradeBillReader, error) {
+	rc, err := b.details.Open()
+	if err != nil {
+		return nil, err
+	}
+	return NewTradeBillReader(rc), nil
+}
+
+// AccountBill returns a reader of the details of a signcustomer bill, it
+// must be closed after use.
+func (b *Bill) AccountBill() (*AccountBillReader, error) {
+	rc, err := b.details.Open()
+	if err != nil {
+		return nil, err
+	}
+	return NewAccountBillReader(rc), nil
+}
+
+// Summary returns a reader of the summary, it must be closed after use.
+func (b *Bill) Summary() (*BillSummaryReader, error) {
+	rc, err := b.summary.Open()
+	if err != nil {
+		return nil, err
+	}
+	return NewBillSummaryReader(rc), nil
+}
+
+// billScanner splits a GBK encoded bill CSV into records keyed by the
+// header, the lines starting with "#" around the table are skipped.
+type billScanner struct {
+	r      *csv.Reader
+	c      io.Closer
+	header []string
+}
+
+func newBillScanner(r io.Reader) *billScanner {
+	cr := csv.NewReader(transform.NewReader(r, simplifiedchinese.GBK.NewDecoder()))
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	b := &billScanner{r: cr}
+	if c, ok := r.(io.Closer); ok {
+		b.c = c
+	}
+	return b
+}
+
+// next returns the next row, or io.EOF at the end of the table.
+func (b *billScanner) next() (*bill.Record, error) {
+	for {
+		vals, err := b.r.Read()
+		if err != nil {
+			return nil, err
+		}
+		if b.header == nil {
+			for _, v := range vals {
+				b.header = append(b.header, strings.TrimSpace(v))
+			}
+			continue
+		}
+		m := make(map[string]string, len(b.header))
+		for i, k := range b.header {
+			if i < len(vals) {
+				m[k] = strings.TrimSpace(vals[i])
+			}
+		}
+		return &bill.Record{M: m}, nil
+	}
+}
+
+func (b *billScanner) close() error {
+	if b.c == nil {
+		return nil
+	}
+	return b.c.Close()
+}
+
+// Fen converts an amount in yuan, e.g. the TotalAmount of NotifyReq, to
+// fen, amounts of more than 2 decimals are rounded half away from zero.
+func Fen(yuan string) (int64, error) {
+	return bill.Fen(yuan)
+}
+
+// TradeBillRow is a row of the trade bill details, amounts are in fen.
+// TradeNo and OutTradeNo match the ones of NotifyReq. ServiceFee and
+// Royalty may have more than 2 decimals and are rounded, their *Yuan
+// fields keep them exact so that the rows add up to the summary.
+type TradeBillRow struct {
+	TradeNo           string
+	OutTradeNo        string
+	BizType           string
+	Subject           string
+	CreateTime        string
+	FinishTime        string
+	StoreID           string
+	StoreName         string
+	OperatorID        string
+	TerminalID        string
+	BuyerLogonID      string
+	TotalAmount       int64
+	ReceiptAmount     int64
+	RedPacketAmount   int64
+	PointAmount       int64
+	AlipayDiscount    int64
+	MerchantDiscount  int64
+	VoucherAmount     int64
+	VoucherName       string
+	MerchantRedPacket int64
+	CardAmount        int64
+	OutRequestNo      string
+	ServiceFee        int64
+	ServiceFeeYuan    string
+	Royalty           int64
+	RoyaltyYuan       string
+	Remark            string
+	// Fields holds all columns keyed by their original names.
+	Fields map[string]string
+}
+
+// TradeBillReader reads the trade bill details row by row.
+type TradeBillReader struct {
+	s *billScanner
+}
+
+// NewTradeBillReader returns a reader of the GBK encoded trade bill details
+// CSV.
+func NewTradeBillReader(r io.Reader) *TradeBillReader {
+	return &TradeBillReader{s: newBillScanner(r)}
+}
+
+// Read returns the next row, or io.EOF at the end of the rows.
+func (t *TradeBillReader) Read() (*TradeBillRow, error) {
+	r, err := t.s.next()
+	if err != nil {
+		return nil, err
+	}
+	row := &TradeBillRow{
+		TradeNo:           r.Str("支付宝交易号"),
+		OutTradeNo:        r.Str("商户订单号"),
+		BizType:           r.Str("业务类型"),
+		Subject:           r.Str("商品名称"),
+		CreateTime:        r.Str("创建时间"),
+		FinishTime:        r.Str("完成时间"),
+		StoreID:           r.Str("门店编号"),
+		StoreName:         r.Str("门店名称"),
+		OperatorID:        r.Str("操作员"),
+		TerminalID:        r.Str("终端号"),
+		BuyerLogonID:      r.Str("对方账户"),
+		TotalAmount:       r.Fen("订单金额（元）", "订单金额(元)"),
+		ReceiptAmount:     r.Fen("商家实收（元）", "商家实收(元)"),
+		RedPacketAmount:   r.Fen("支付宝红包（元）", "支付宝红包(元)"),
+		PointAmount:       r.Fen("集分宝（元）", "集分宝(元)"),
+		AlipayDiscount:    r.Fen("支付宝优惠（元）", "支付宝优惠(元)"),
+		MerchantDiscount:  r.Fen("商家优惠（元）", "商家优惠(元)"),
+		VoucherAmount:     r.Fen("券核销金额（元）", "券核销金额(元)"),
+		VoucherName:       r.Str("券名称"),
+		MerchantRedPacket: r.Fen("商家红包消费金额（元）", "商家红包消费金额(元)"),
+		CardAmount:        r.Fen("卡消费金额（元）", "卡消费金额(元)"),
+		OutRequestNo:      r.Str("退款批次号/请求号"),
+		ServiceFee:        r.Fen("服务费（元）", "服务费(元)"),
+		ServiceFeeYuan:    r.Str("服务费（元）", "服务费(元)"),
+		Royalty:           r.Fen("分润（元）", "分润(元)"),
+		RoyaltyYuan:       r.Str("分润（元）", "分润(元)"),
+		Remark:            r.Str("备注"),
+		Fields:            r.M,
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return row, nil
+}
+
+// Close closes the underlying reader if it is an io.Closer.
+func (t *TradeBillReader) Close() error {
+	return t.s.close()
+}
+
+// AccountBillRow is a row of the signcustomer bill details, amounts are in
+// fen and Expense is negative.
+type AccountBillRow struct {
+	FlowNo     string
+	BizNo      string
+	OutTradeNo string
+	Subject    string
+	Time       string
+	Account    string
+	Income     int64
+	Expense    int64
+	Balance    int64
+	Channel    string
+	BizType    string
+	Remark     string
+	Fields     map[string]string
+}
+
+// AccountBillReader reads the signcustomer bill details row by row.
+type AccountBillReader struct {
+	s *billScanner
+}
+
+// NewAccountBillReader returns a reader of the GBK encoded signcustomer
+// bill details CSV.
+func NewAccountBillReader(r io.Reader) *AccountBillReader {
+	return &AccountBillReader{s: newBillScanner(r)}
+}
+
+// Read returns the next row, or io.EOF at the end of the rows.
+func (a *AccountBillReader) Read() (*AccountBillRow, error) {
+	r, err := a.s.next()
+	if err != nil {
+		return nil, err
+	}
+	row := &AccountBillRow{
+		FlowNo:     r.Str("账务流水号"),
+		BizNo:      r.Str("业务流水号"),
+		OutTradeNo: r.Str("商户订单号"),
+		Subject:    r.Str("商品名称"),
+		Time:       r.Str("发生时间"),
+		Account:    r.Str("对方账号"),
+		Income:     r.Fen("收入金额（+元）", "收入金额(+元)"),
+		Expense:    r.Fen("支出金额（-元）", "支出金额(-元)"),
+		Balance:    r.Fen("账户余额（元）", "账户余额(元)"),
+		Channel:    r.Str("交易渠道"),
+		BizType:    r.Str("业务类型"),
+		Remark:     r.Str("备注"),
+		Fields:     r.M,
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if row.Expense > 0 {
+		row.Expense = -row.Expense
+	}
+	return row, nil
+}
+
+// Close closes the underlying reader if it is an io.Closer.
+func (a *AccountBillReader) Close() error {
+	return a.s.close()
+}
+
+// BillSummaryRow is a row of the bill summary, amounts are in fen and the
+// *Yuan fields are the exact ServiceFee and Royalty. The rows of a trade
+// bill summary are per store and the last one, whose StoreID is "合计",
+// holds the totals. Fields holds the columns of signcustomer summaries,
+// which are per business type.
+type BillSummaryRow struct {
+	StoreID          string
+	StoreName        string
+	TradeCount       int
+	RefundCount      int
+	TotalAmount      int64
+	ReceiptAmount    int64
+	AlipayDiscount   int64
+	MerchantDiscount int64
+	CardAmount       int64
+	ServiceFee       int64
+	ServiceFeeYuan   string
+	Royalty          int64
+	RoyaltyYuan      string
+	NetAmount        int64
+	Fields           map[string]string
+}
+
+// IsTotal tells whether r is the totals row.
+func (r *BillSummaryRow) IsTotal() bool {
+	return r.StoreID == "合计"
+}
+
+// BillSummaryReader reads the bill summary row by row.
+type BillSummaryReader struct {
+	s *billScanner
+}
+
+// NewBillSummaryReader returns a reader of the GBK encoded bill summary
+// CSV.
+func NewBillSummaryReader(r io.Reader) *BillSummaryReader {
+	return &BillSummaryReader{s: newBillScanner(r)}
+}
+
+// Read returns the next row, or io.EOF at the end of the rows.
+func (b *BillSummaryReader) Read() (*BillSummaryRow, error) {
+	r, err := b.s.next()
+	if err != nil {
+		return nil, err
+	}
+	row := &BillSummaryRow{
+		StoreID:          r.Str("门店编号"),
+		StoreName:        r.Str("门店名称"),
+		TradeCount:       r.Int("交易订单总笔数"),
+		RefundCount:      r.Int("退款订单总笔数"),
+		TotalAmount:      r.Fen("订单金额（元）", "订单金额(元)"),
+		ReceiptAmount:    r.Fen("商家实收（元）", "商家实收(元)"),
+		AlipayDiscount:   r.Fen("支付宝优惠（元）", "支付宝优惠(元)"),
+		MerchantDiscount: r.Fen("商家优惠（元）", "商家优惠(元)"),
+		CardAmount:       r.Fen("卡消费金额（元）", "卡消费金额(元)"),
+		ServiceFee:       r.Fen("服务费（元）", "服务费(元)"),
+		ServiceFeeYuan:   r.Str("服务费（元）", "服务费(元)"),
+		Royalty:          r.Fen("分润（元）", "分润(元)"),
+		RoyaltyYuan:      r.Str("分润（元）", "分润(元)"),
+		NetAmount:        r.Fen("实收净额（元）", "实收净额(元)"),
+		Fields:           r.M,
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return row, nil
+}
+
+// Close closes the underlying reader if it is an io.Closer.
+func (b *BillSummaryReader) Close() error {
+	return b.s.close()
+}
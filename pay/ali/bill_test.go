@@ -0,0 +1,143 @@
+package ali
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+const tradeDetails = "#支付宝业务明细查询\n" +
+	"#账号：[20880000000000000156]\n" +
+	"#起始日期：[2018年01月02日 00:00:00]   终止日期：[2018年01月03日 00:00:00]\n" +
+	"#-----------------------------------------业务明细列表----------------------------------------\n" +
+	"支付宝交易号,商户订单号,业务类型,商品名称,创建时间,完成时间,门店编号,门店名称,操作员,终端号,对方账户,订单金额（元）,商家实收（元）,支付宝红包（元）,集分宝（元）,支付宝优惠（元）,商家优惠（元）,券核销金额（元）,券名称,商家红包消费金额（元）,卡消费金额（元）,退款批次号/请求号,服务费（元）,分润（元）,备注\n" +
+	"2018010221001004\t,o1\t,交易,商品,2018-01-02 10:00:00,2018-01-02 10:00:05,,,,,a***@b.com,1.00,1.00,0.00,0.00,0.00,0.00,0.00,,0.00,0.00,,-0.006,0.00,\n" +
+	"2018010221001005\t,o2\t,退款,\"商品,退\",2018-01-02 11:00:00,2018-01-02 11:00:05,,,,,a***@b.com,-0.50,-0.50,0.00,0.00,0.00,0.00,0.00,,0.00,0.00,r1,0.00,0.00,\n" +
+	"#-----------------------------------------业务明细列表结束------------------------------------\n" +
+	"#导出时间：[2018年01月03日 09:00:00]\n"
+
+const tradeSummary = "#支付宝业务汇总查询\n" +
+	"门店编号,门店名称,交易订单总笔数,退款订单总笔数,订单金额（元）,商家实收（元）,支付宝优惠（元）,商家优惠（元）,卡消费金额（元）,服务费（元）,分润（元）,实收净额（元）\n" +
+	"合计,,1,1,0.50,0.50,0.00,0.00,0.00,-0.01,0.00,0.49\n" +
+	"#导出时间：[2018年01月03日 09:00:00]\n"
+
+func gbk(t *testing.T, s string) string {
+	b, err := simplifiedchinese.GBK.NewEncoder().String(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func billZip(t *testing.T) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name, body string
+	}{
+		// zip tools on Windows write GBK names without the UTF-8 flag.
+		{"20880000000000000156_20180102_业务明细.csv", tradeDetails},
+		{gbk(t, "20880000000000000156_20180102_业务明细(汇总).csv"), tradeSummary},
+		{"readme.txt", "ignored"},
+	} {
+		w, err := z.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, gbk(t, f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOpenBill(t *testing.T) {
+	b := billZip(t)
+	bill, err := OpenBill(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := bill.TradeBill()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	var rows []*TradeBillRow
+	for {
+		row, err := tr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows", len(rows))
+	}
+	if r := rows[0]; r.TradeNo != "2018010221001004" || r.OutTradeNo != "o1" || r.TotalAmount != 100 || r.ServiceFee != -1 || r.ServiceFeeYuan != "-0.006" || r.BuyerLogonID != "a***@b.com" {
+		t.Errorf("row 0 = %+v", r)
+	}
+	if r := rows[1]; r.BizType != "退款" || r.Subject != "商品,退" || r.TotalAmount != -50 || r.OutRequestNo != "r1" {
+		t.Errorf("row 1 = %+v", r)
+	}
+
+	sr, err := bill.Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+	s, err := sr.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsTotal() || s.TradeCount != 1 || s.RefundCount != 1 || s.TotalAmount != 50 || s.ServiceFee != -1 || s.ServiceFeeYuan != "-0.01" || s.NetAmount != 49 {
+		t.Errorf("summary = %+v", s)
+	}
+	if _, err := sr.Read(); err != io.EOF {
+		t.Errorf("got %v, want io.EOF", err)
+	}
+}
+
+func TestOpenBillMissing(t *testing.T) {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	if _, err := z.Create("readme.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenBill(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err == nil {
+		t.Error("want error for a zip without bill")
+	}
+}
+
+func TestFen(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"0.01", 1, true},
+		{"88.88", 8888, true},
+		{"-0.50", -50, true},
+		{"0.005", 1, true},
+		{"", 0, false},
+		{"1,000.00", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := Fen(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Fen(%q) = %d, %v", tt.in, got, err)
+		}
+	}
+}
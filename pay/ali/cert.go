@@ -0,0 +1,128 @@
+package ali
+
+import (
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func parseCert(b []byte) (*x509.Certificate, error) {
+	p, _ := pem.Decode(b)
+	if p == nil {
+		return nil, errors.New("ali: failed to parse certificate PEM")
+	}
+	return x509.ParseCertificate(p.Bytes)
+}
+
+// attributeTypeNames are the RFC 2253 names of the issuer attributes.
+var attributeTypeNames = map[string]string{
+	"2.5.4.6":  "C",
+	"2.5.4.10": "O",
+	"2.5.4.11": "OU",
+	"2.5.4.3":  "CN",
+	"2.5.4.5":  "SERIALNUMBER",
+	"2.5.4.7":  "L",
+	"2.5.4.8":  "ST",
+	"2.5.4.9":  "STREET",
+	"2.5.4.17": "POSTALCODE",
+}
+
+// issuerDN is the RFC 2253 issuer of c like CN=x,O=y,C=CN, which is
+// pkix.Name.String from Go 1.10.
+func issuerDN(c *x509.Certificate) string {
+	names := c.Issuer.Names
+	dn := make([]string, 0, len(names))
+	for i := len(names) - 1; i >= 0; i-- {
+		t := names[i].Type.String()
+		if n, ok := attributeTypeNames[t]; ok {
+			t = n
+		}
+		dn = append(dn, fmt.Sprintf("%s=%v", t, names[i].Value))
+	}
+	return strings.Join(dn, ",")
+}
+
+func certSN(c *x509.Certificate) string {
+	sum := md5.Sum([]byte(issuerDN(c) + c.SerialNumber.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// CertSN returns the SN of a PEM certificate, which is the MD5 of its issuer
+// and serial number, as app_cert_sn and alipay_cert_sn.
+func CertSN(b []byte) (string, error) {
+	c, err := parseCert(b)
+	if err != nil {
+		return "", err
+	}
+	return certSN(c), nil
+}
+
+// RootCertSN returns alipay_root_cert_sn of the PEM Alipay root chain, the
+// SNs of its RSA certificates joined with "_".
+func RootCertSN(b []byte) (string, error) {
+	var sns []string
+	for {
+		var p *pem.Block
+		if p, b = pem.Decode(b); p == nil {
+			break
+		}
+		c, err := x509.ParseCertificate(p.Bytes)
+		if err != nil {
+			// the chain carries certificates of algorithms like SM2 which
+			// are not used here.
+			continue
+		}
+		if c.SignatureAlgorithm == x509.SHA1WithRSA || c.SignatureAlgorithm == x509.SHA256WithRSA {
+			sns = append(sns, certSN(c))
+		}
+	}
+	if len(sns) == 0 {
+		return "", errors.New("ali: no RSA certificate in root chain")
+	}
+	return strings.Join(sns, "_"), nil
+}
+
+// AddAlipayCert adds a PEM Alipay public key certificate, responses are
+// verified with the one matching their alipay_cert_sn, so a rotated
+// certificate can be added alongside the old one.
+func (c *Client) AddAlipayCert(b []byte) error {
+	cert, err := parseCert(b)
+	if err != nil {
+		return err
+	}
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("ali: Alipay certificate is not RSA")
+	}
+	c.mu.Lock()
+	if c.certs == nil {
+		c.certs = map[string]*rsa.PublicKey{}
+	}
+	c.certs[certSN(cert)] = pub
+	c.mu.Unlock()
+	return nil
+}
+
+// alipayKey returns the key verifying a response signed by the certificate
+// of sn, or the Alipay public key out of certificate mode.
+func (c *Client) alipayKey(sn string) (*rsa.PublicKey, error) {
+	if c.appCertSN == "" {
+		return c.pub, nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if pub, ok := c.certs[sn]; ok {
+		return pub, nil
+	}
+	if sn == "" && len(c.certs) == 1 {
+		for _, pub := range c.certs {
+			return pub, nil
+		}
+	}
+	return nil, fmt.Errorf("ali: no Alipay certificate of SN %q", sn)
+}
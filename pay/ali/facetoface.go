@@ -0,0 +1,150 @@
+package ali
+
+import (
+	"errors"
+	"time"
+
+	"github.com/douglarek/apikit/pay/internal/poll"
+)
+
+const methodPay = "alipay.trade.pay"
+
+// ProductFaceToFace is the product code of face to face payments.
+const ProductFaceToFace = "FACE_TO_FACE_PAYMENT"
+
+// SceneBarCode charges the payment code scanned from the user.
+const SceneBarCode = "bar_code"
+
+// codes of pending and unknown trade.pay results.
+const (
+	codeInProcess = "10003"
+	codeUnknown   = "20000"
+)
+
+// ErrCanceled is returned by BarcodePay and WaitPaid when the trade is not
+// paid before the deadline and has been canceled.
+var ErrCanceled = errors.New("ali: trade timed out, canceled")
+
+// TradePayReq is the biz_content of trade.pay, Scene and ProductCode
+// default to SceneBarCode and ProductFaceToFace.
+type TradePayReq struct {
+	OutTradeNo     string `json:"out_trade_no"`
+	Scene          string `json:"scene"`
+	AuthCode       string `json:"auth_code"`
+	ProductCode    string `json:"product_code,omitempty"`
+	Subject        string `json:"subject"`
+	TotalAmount    string `json:"total_amount"`
+	Body           string `json:"body,omitempty"`
+	OperatorID     string `json:"operator_id,omitempty"`
+	StoreID        string `json:"store_id,omitempty"`
+	TerminalID     string `json:"terminal_id,omitempty"`
+	TimeoutExpress string `json:"timeout_express,omitempty"`
+	NotifyURL      string `json:"-"`
+}
+
+// TradePayResp ...
+type TradePayResp struct {
+	RespCommon
+	TradeNo        string `json:"trade_no"`
+	OutTradeNo     string `json:"out_trade_no"`
+	BuyerLogonID   string `json:"buyer_logon_id"`
+	TotalAmount    string `json:"total_amount"`
+	ReceiptAmount  string `json:"receipt_amount"`
+	BuyerPayAmount string `json:"buyer_pay_amount"`
+	PointAmount    string `json:"point_amount"`
+	InvoiceAmount  string `json:"invoice_amount"`
+	GmtPayment     string `json:"gmt_payment"`
+	StoreName      string `json:"store_name"`
+	BuyerUserID    string `json:"buyer_user_id"`
+}
+
+// Pay charges the payment code scanned from the user, a pending result is
+// returned as *Error with code 10003 and needs to be polled, see
+// BarcodePay.
+func (c *Client) Pay(r *TradePayReq) (*TradePayResp, error) {
+	if r.Scene == "" {
+		r.Scene = SceneBarCode
+	}
+	if r.ProductCode == "" {
+		r.ProductCode = ProductFaceToFace
+	}
+	res := new(TradePayResp)
+	if err := c.do(methodPay, r, r.NotifyURL, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// BarcodePay sends r, polls Query with backoff while the user is paying,
+// and cancels the trade if it is not paid before deadline. The returned
+// trade is TRADE_SUCCESS, or closed with an error.
+func (c *Client) BarcodePay(r *TradePayReq, deadline time.Time) (*TradeQueryResp, error) {
+	res, err := c.Pay(r)
+	if err == nil {
+		return payQueryResp(res), nil
+	}
+	if e, ok := err.(*Error); ok && e.Code != codeInProcess && e.Code != codeUnknown && e.SubCode != "ACQ.SYSTEM_ERROR" {
+		return nil, err
+	}
+	// the result is pending or unknown, poll until it is final.
+	return c.WaitPaid(r.OutTradeNo, deadline)
+}
+
+// WaitPaid polls Query with backoff until the trade of outTradeNo is paid
+// or closed, e.g. after Precreate, and cancels it at deadline.
+func (c *Client) WaitPaid(outTradeNo string, deadline time.Time) (*TradeQueryResp, error) {
+	q := &TradeQueryReq{OutTradeNo: outTradeNo}
+	var qr *TradeQueryResp
+	var err error
+	if poll.Until(deadline, func() bool {
+		// errors like ACQ.TRADE_NOT_EXIST are retried, the trade may not
+		// have been created yet.
+		qr, err = c.Query(q)
+		if err != nil {
+			return false
+		}
+		if qr.TradeStatus == TradeSuccess || qr.TradeStatus == TradeFinished {
+			return true
+		}
+		if qr.TradeStatus.IsFinal() {
+			err = errors.New("ali: trade closed")
+			return true
+		}
+		return false
+	}) {
+		return qr, err
+	}
+
+	cr := &TradeCancelReq{OutTradeNo: outTradeNo}
+	if poll.Retry(func() bool {
+		var res *TradeCancelResp
+		res, err = c.Cancel(cr)
+		if err == nil && res.RetryFlag != "Y" {
+			err = ErrCanceled
+			return true
+		}
+		e, ok := err.(*Error)
+		return ok && e.SubCode != "ACQ.SYSTEM_ERROR"
+	}) {
+		return nil, err
+	}
+	return nil, errors.New("ali: trade timed out, cancel failed")
+}
+
+func payQueryResp(r *TradePayResp) *TradeQueryResp {
+	return &TradeQueryResp{
+		RespCommon:     r.RespCommon,
+		TradeNo:        r.TradeNo,
+		OutTradeNo:     r.OutTradeNo,
+		BuyerLogonID:   r.BuyerLogonID,
+		TradeStatus:    TradeSuccess,
+		TotalAmount:    r.TotalAmount,
+		ReceiptAmount:  r.ReceiptAmount,
+		BuyerPayAmount: r.BuyerPayAmount,
+		PointAmount:    r.PointAmount,
+		InvoiceAmount:  r.InvoiceAmount,
+		SendPayDate:    r.GmtPayment,
+		StoreName:      r.StoreName,
+		BuyerUserID:    r.BuyerUserID,
+	}
+}
@@ -0,0 +1,72 @@
+package ali
+
+import (
+	"bytes"
+	"crypto"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/douglarek/apikit"
+	"github.com/fatih/structs"
+)
+
+// MobileService is the service of the legacy mobile app payment.
+const MobileService = "mobile.securitypay.pay"
+
+// MobileOrderString returns the order string of the legacy
+// mobile.securitypay.pay protocol passed to the Alipay SDK: the sorted
+// params as k="v" are RSA (SHA1) signed, followed by the quoted URL encoded
+// sign and sign_type="RSA". privateKey is PEM or bare base64 encoded.
+func (a *Ali) MobileOrderString(r *OrderReq, privateKey []byte) (string, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	m := removeKeys(apikit.Params(structs.Map(r)), "sign", "sign_type")
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteString("&")
+		}
+		buf.WriteString(k + `="` + m[k] + `"`)
+	}
+	sig, err := signRSA(key, crypto.SHA1, buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+	buf.WriteString(`&sign="` + javaEscape(sig) + `"&sign_type="` + RSA + `"`)
+	return buf.String(), nil
+}
+
+// AppOrderString encodes the signed params of alipay.trade.app.pay as the
+// order string passed to the Alipay SDK: sorted k=v pairs whose values are
+// escaped like java.net.URLEncoder.
+func AppOrderString(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteString("&")
+		}
+		buf.WriteString(k + "=" + javaEscape(m[k]))
+	}
+	return buf.String()
+}
+
+// javaEscape escapes s like java.net.URLEncoder, which keeps "*" but not
+// "~" unlike url.QueryEscape.
+func javaEscape(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.Replace(s, "%2A", "*", -1)
+	return strings.Replace(s, "~", "%7E", -1)
+}
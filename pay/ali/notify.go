@@ -0,0 +1,119 @@
+package ali
+
+import (
+	"crypto/md5"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// NotifyConfig configures the notification handler, AppID, SellerID and
+// Partner are checked only when given.
+type NotifyConfig struct {
+	// Partner enables notify_id verification of legacy notifications.
+	Partner  string
+	AppID    string
+	SellerID string
+	// AlipayPublicKey verifies RSA and RSA2 signatures.
+	AlipayPublicKey []byte
+	// MD5Key verifies MD5 signatures.
+	MD5Key string
+}
+
+type notifyHandler struct {
+	a      *Ali
+	c      NotifyConfig
+	pub    *rsa.PublicKey
+	handle func(*NotifyReq) error
+}
+
+// NotifyHandler returns a handler which verifies the signature over all
+// posted parameters, checks notify_id and the configured ids, then calls
+// handle and answers success if it returns nil, fail otherwise.
+func (a *Ali) NotifyHandler(c NotifyConfig, handle func(*NotifyReq) error) (http.Handler, error) {
+	h := &notifyHandler{a: a, c: c, handle: handle}
+	if len(c.AlipayPublicKey) != 0 {
+		pub, err := parsePublicKey(c.AlipayPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		h.pub = pub
+	}
+	return h, nil
+}
+
+func (h *notifyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.serve(r); err != nil {
+		w.Write([]byte("fail"))
+		return
+	}
+	w.Write([]byte("success"))
+}
+
+func (h *notifyHandler) serve(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	// only the posted params are signed, the query of the notify URL is not.
+	m := make(map[string]string, len(r.PostForm))
+	for k := range r.PostForm {
+		m[k] = r.PostForm.Get(k)
+	}
+	if err := h.verify(m); err != nil {
+		return err
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	req := new(NotifyReq)
+	if err := json.Unmarshal(b, req); err != nil {
+		return err
+	}
+	req.Params = m
+	if h.c.AppID != "" && req.AppID != h.c.AppID {
+		return fmt.Errorf("ali: app_id mismatch: %s", req.AppID)
+	}
+	if h.c.SellerID != "" && req.SellerID != h.c.SellerID {
+		return fmt.Errorf("ali: seller_id mismatch: %s", req.SellerID)
+	}
+	if h.c.Partner != "" {
+		if err := h.a.VerifyNotifyID(h.c.Partner, req.NotifyID); err != nil {
+			return err
+		}
+	}
+	return h.handle(req)
+}
+
+func (h *notifyHandler) verify(m map[string]string) error {
+	sign, st := m["sign"], m["sign_type"]
+	b := joinParams(removeKeys(copyParams(m), "sign", "sign_type"))
+	switch st {
+	case MD5:
+		if h.c.MD5Key == "" {
+			return errors.New("ali: no MD5 key configured")
+		}
+		sum := md5.Sum(append(b, h.c.MD5Key...))
+		if hex.EncodeToString(sum[:]) != sign {
+			return errors.New("ali: MD5 sign mismatch")
+		}
+		return nil
+	case RSA, RSA2:
+		if h.pub == nil {
+			return errors.New("ali: no Alipay public key configured")
+		}
+		return verifyRSA(h.pub, signHash(st), b, sign)
+	}
+	return fmt.Errorf("ali: unsupported sign_type %q", st)
+}
+
+func copyParams(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
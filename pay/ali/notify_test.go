@@ -0,0 +1,41 @@
+package ali
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNotifyHandlerIgnoresQuery(t *testing.T) {
+	form := url.Values{
+		"app_id":       {"2018"},
+		"out_trade_no": {"o1"},
+		"trade_status": {"TRADE_SUCCESS"},
+		"sign_type":    {MD5},
+	}
+	sum := md5.Sum([]byte("app_id=2018&out_trade_no=o1&trade_status=TRADE_SUCCESS" + "key"))
+	form.Set("sign", hex.EncodeToString(sum[:]))
+
+	var got *NotifyReq
+	h, err := New(nil).NotifyHandler(NotifyConfig{AppID: "2018", MD5Key: "key"}, func(r *NotifyReq) error {
+		got = r
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/notify?shop=1", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "success" {
+		t.Fatalf("got %d %q", rec.Code, rec.Body.String())
+	}
+	if got == nil || got.OutTradeNo != "o1" || got.Params["shop"] != "" {
+		t.Errorf("notify = %+v", got)
+	}
+}
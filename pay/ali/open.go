@@ -0,0 +1,367 @@
+package ali
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/douglarek/apikit"
+	"github.com/fatih/structs"
+)
+
+const gatewayURL = "https://openapi.alipay.com/gateway.do"
+
+// success code of open platform responses.
+const codeSuccess = "10000"
+
+// Config is the configuration of an open platform Client.
+type Config struct {
+	AppID string
+	// PrivateKey is the app private key, PEM or bare base64 encoded
+	// PKCS#1 or PKCS#8.
+	PrivateKey []byte
+	// AlipayPublicKey is the Alipay public key verifying responses, PEM or
+	// bare base64. It is not needed in certificate mode.
+	AlipayPublicKey []byte
+	// AppCert, AlipayRootCert and AlipayCert enable the public key
+	// certificate mode, they are the PEM contents of appCertPublicKey.crt,
+	// alipayRootCert.crt and alipayCertPublicKey_RSA2.crt.
+	AppCert        []byte
+	AlipayRootCert []byte
+	AlipayCert     []byte
+	// Gateway defaults to https://openapi.alipay.com/gateway.do.
+	Gateway   string
+	NotifyURL string
+	ReturnURL string
+}
+
+// Client handles communication with the Alipay open platform gateway.
+type Client struct {
+	client  *apikit.Client
+	appID   string
+	key     *rsa.PrivateKey
+	pub     *rsa.PublicKey
+	gateway string
+	notify  string
+	ret     string
+
+	// certificate mode
+	appCertSN  string
+	rootCertSN string
+	mu         sync.RWMutex
+	certs      map[string]*rsa.PublicKey
+}
+
+// NewClient makes an open platform client.
+func NewClient(httpClient *http.Client, c Config) (*Client, error) {
+	key, err := parsePrivateKey(c.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if c.Gateway == "" {
+		c.Gateway = gatewayURL
+	}
+	cl := &Client{
+		client:  apikit.NewClient(httpClient),
+		appID:   c.AppID,
+		key:     key,
+		gateway: c.Gateway,
+		notify:  c.NotifyURL,
+		ret:     c.ReturnURL,
+	}
+	if len(c.AppCert) == 0 {
+		if cl.pub, err = parsePublicKey(c.AlipayPublicKey); err != nil {
+			return nil, err
+		}
+		return cl, nil
+	}
+	if cl.appCertSN, err = CertSN(c.AppCert); err != nil {
+		return nil, err
+	}
+	if cl.rootCertSN, err = RootCertSN(c.AlipayRootCert); err != nil {
+		return nil, err
+	}
+	cl.certs = map[string]*rsa.PublicKey{}
+	if err := cl.AddAlipayCert(c.AlipayCert); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// decodeKey returns the DER of a PEM or bare base64 encoded key.
+func decodeKey(b []byte) ([]byte, error) {
+	if p, _ := pem.Decode(b); p != nil {
+		return p.Bytes, nil
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(b)), ""))
+	if err != nil {
+		return nil, errors.New("ali: key is neither PEM nor base64")
+	}
+	return der, nil
+}
+
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	der, err := decodeKey(b)
+	if err != nil {
+		return nil, err
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	rk, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("ali: private key is not RSA")
+	}
+	return rk, nil
+}
+
+// parsePKCS1PublicKey is x509.ParsePKCS1PublicKey, which needs Go 1.10.
+func parsePKCS1PublicKey(der []byte) (*rsa.PublicKey, error) {
+	var k struct {
+		N *big.Int
+		E int
+	}
+	rest, err := asn1.Unmarshal(der, &k)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 || k.N == nil || k.N.Sign() <= 0 || k.E <= 0 {
+		return nil, errors.New("ali: malformed PKCS#1 public key")
+	}
+	return &rsa.PublicKey{N: k.N, E: k.E}, nil
+}
+
+// parsePublicKey accepts a public key or a certificate.
+func parsePublicKey(b []byte) (*rsa.PublicKey, error) {
+	der, err := decodeKey(b)
+	if err != nil {
+		return nil, err
+	}
+	if k, err := parsePKCS1PublicKey(der); err == nil {
+		return k, nil
+	}
+	var k interface{}
+	if c, err := x509.ParseCertificate(der); err == nil {
+		k = c.PublicKey
+	} else if k, err = x509.ParsePKIXPublicKey(der); err != nil {
+		return nil, err
+	}
+	pub, ok := k.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("ali: public key is not RSA")
+	}
+	return pub, nil
+}
+
+// openReq holds the common params of open platform requests.
+type openReq struct {
+	AppID        string `structs:"app_id"`
+	Method       string `structs:"method"`
+	Format       string `structs:"format"`
+	Charset      string `structs:"charset"`
+	SignType     string `structs:"sign_type"`
+	Sign         string `structs:"sign"`
+	Timestamp    string `structs:"timestamp"`
+	Version      string `structs:"version"`
+	NotifyURL    string `structs:"notify_url"`
+	ReturnURL    string `structs:"return_url"`
+	AppAuthToken string `structs:"app_auth_token"`
+	BizContent   string `structs:"biz_content"`
+	// certificate mode only
+	AppCertSN        string `structs:"app_cert_sn"`
+	AlipayRootCertSN string `structs:"alipay_root_cert_sn"`
+}
+
+var cst = time.FixedZone("CST", 8*60*60)
+
+// newReq builds the signed request of method, notifyURL and returnURL
+// default to the configured ones.
+func (c *Client) newReq(method string, biz interface{}, notifyURL, returnURL string) (*openReq, error) {
+	b, err := json.Marshal(biz)
+	if err != nil {
+		return nil, err
+	}
+	if notifyURL == "" {
+		notifyURL = c.notify
+	}
+	if returnURL == "" {
+		returnURL = c.ret
+	}
+	r := &openReq{
+		AppID:      c.appID,
+		Method:     method,
+		Format:     "JSON",
+		Charset:    "utf-8",
+		SignType:   RSA2,
+		Timestamp:  time.Now().In(cst).Format("2006-01-02 15:04:05"),
+		Version:    "1.0",
+		NotifyURL:  notifyURL,
+		ReturnURL:  returnURL,
+		BizContent: string(b),
+
+		AppCertSN:        c.appCertSN,
+		AlipayRootCertSN: c.rootCertSN,
+	}
+	m := apikit.Params(structs.Map(r))
+	delete(m, "sign")
+	if r.Sign, err = signRSA(c.key, crypto.SHA256, joinParams(m)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// joinParams joins non-empty m as sorted k=v pairs without any quoting or
+// escaping.
+func joinParams(m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for k, v := range m {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteString("&")
+		}
+		buf.WriteString(k + "=" + m[k])
+	}
+	return buf.Bytes()
+}
+
+func values(r *openReq) url.Values {
+	v := url.Values{}
+	for k, val := range apikit.Params(structs.Map(r)) {
+		v.Set(k, val)
+	}
+	return v
+}
+
+func signRSA(key *rsa.PrivateKey, hash crypto.Hash, b []byte) (string, error) {
+	h := hash.New()
+	h.Write(b)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, hash, h.Sum(nil))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+func verifyRSA(pub *rsa.PublicKey, hash crypto.Hash, b []byte, sign string) error {
+	sig, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write(b)
+	return rsa.VerifyPKCS1v15(pub, hash, h.Sum(nil), sig)
+}
+
+// RespCommon holds the common fields of open platform responses.
+type RespCommon struct {
+	Code    string `json:"code"`
+	Msg     string `json:"msg"`
+	SubCode string `json:"sub_code,omitempty"`
+	SubMsg  string `json:"sub_msg,omitempty"`
+}
+
+// Error is a failed open platform response, SubCode tells the reason, e.g.
+// ACQ.TRADE_NOT_EXIST.
+type Error struct {
+	RespCommon
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ali: %s %s: %s %s", e.Code, e.Msg, e.SubCode, e.SubMsg)
+}
+
+// do calls method on the gateway, verifies the response node and decodes
+// it into v, a response whose code isn't 10000 is returned as *Error.
+func (c *Client) do(method string, biz interface{}, notifyURL string, v interface{}) error {
+	r, err := c.newReq(method, biz, notifyURL, "")
+	if err != nil {
+		return err
+	}
+	req, err := c.client.NewRequest("POST", c.gateway, r)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if _, err := c.client.Do(req, &buf); err != nil {
+		return err
+	}
+	return c.decode(method, buf.Bytes(), v)
+}
+
+func (c *Client) decode(method string, b []byte, v interface{}) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	node, ok := m[strings.Replace(method, ".", "_", -1)+"_response"]
+	if !ok {
+		if node, ok = m["error_response"]; !ok {
+			return fmt.Errorf("ali: no response node in %s", b)
+		}
+	}
+	var sign, sn string
+	if s, ok := m["sign"]; ok {
+		if err := json.Unmarshal(s, &sign); err != nil {
+			return err
+		}
+	}
+	if s, ok := m["alipay_cert_sn"]; ok {
+		if err := json.Unmarshal(s, &sn); err != nil {
+			return err
+		}
+	}
+	var rc RespCommon
+	if err := json.Unmarshal(node, &rc); err != nil {
+		return err
+	}
+	if sign != "" {
+		pub, err := c.alipayKey(sn)
+		if err != nil {
+			return err
+		}
+		if err := verifyRSA(pub, crypto.SHA256, node, sign); err != nil {
+			return err
+		}
+	} else if rc.Code == codeSuccess {
+		// only failed responses may be unsigned.
+		return errors.New("ali: response is not signed")
+	}
+	if rc.Code != codeSuccess {
+		return &Error{rc}
+	}
+	return json.Unmarshal(node, v)
+}
+
+// pageURL returns the gateway URL of a page redirected pay method.
+func (c *Client) pageURL(method string, biz interface{}, notifyURL, returnURL string) (string, error) {
+	r, err := c.newReq(method, biz, notifyURL, returnURL)
+	if err != nil {
+		return "", err
+	}
+	return c.gateway + "?" + values(r).Encode(), nil
+}
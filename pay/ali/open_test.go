@@ -0,0 +1,45 @@
+package ali
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs1, err := asn1.Marshal(key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkix, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string][]byte{
+		"pkcs1 pem":    pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: pkcs1}),
+		"pkcs1 base64": []byte(base64.StdEncoding.EncodeToString(pkcs1)),
+		"pkix pem":     pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}),
+		"pkix base64":  []byte(base64.StdEncoding.EncodeToString(pkix)),
+	}
+	for name, b := range tests {
+		pub, err := parsePublicKey(b)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if pub.N.Cmp(key.N) != 0 || pub.E != key.E {
+			t.Errorf("%s: got another key", name)
+		}
+	}
+	if _, err := parsePKCS1PublicKey(append(pkcs1, 0)); err == nil {
+		t.Error("trailing data accepted")
+	}
+}
@@ -0,0 +1,269 @@
+package ali
+
+import (
+	"github.com/douglarek/apikit"
+	"github.com/fatih/structs"
+)
+
+// open platform trade methods
+const (
+	methodAppPay    = "alipay.trade.app.pay"
+	methodWapPay    = "alipay.trade.wap.pay"
+	methodPagePay   = "alipay.trade.page.pay"
+	methodPrecreate = "alipay.trade.precreate"
+	methodQuery     = "alipay.trade.query"
+	methodRefund    = "alipay.trade.refund"
+	methodRefundQry = "alipay.trade.fastpay.refund.query"
+	methodClose     = "alipay.trade.close"
+	methodCancel    = "alipay.trade.cancel"
+)
+
+// product codes
+const (
+	ProductApp  = "QUICK_MSECURITY_PAY"
+	ProductWap  = "QUICK_WAP_WAY"
+	ProductPage = "FAST_INSTANT_TRADE_PAY"
+)
+
+// TradeStatus is the status of a trade.
+type TradeStatus string
+
+// trade statuses
+const (
+	TradeWaitBuyerPay TradeStatus = "WAIT_BUYER_PAY"
+	TradeClosed       TradeStatus = "TRADE_CLOSED"
+	TradeSuccess      TradeStatus = "TRADE_SUCCESS"
+	TradeFinished     TradeStatus = "TRADE_FINISHED"
+)
+
+// IsFinal tells whether the trade won't be paid anymore.
+func (s TradeStatus) IsFinal() bool {
+	return s == TradeClosed || s == TradeSuccess || s == TradeFinished
+}
+
+// TradeOrderReq is the biz_content of app, wap and page pay, ProductCode
+// defaults to the one of the method.
+type TradeOrderReq struct {
+	OutTradeNo     string `json:"out_trade_no"`
+	TotalAmount    string `json:"total_amount"`
+	Subject        string `json:"subject"`
+	ProductCode    string `json:"product_code"`
+	Body           string `json:"body,omitempty"`
+	TimeoutExpress string `json:"timeout_express,omitempty"`
+	TimeExpire     string `json:"time_expire,omitempty"`
+	GoodsType      string `json:"goods_type,omitempty"`
+	PassbackParams string `json:"passback_params,omitempty"`
+	QuitURL        string `json:"quit_url,omitempty"`
+	StoreID        string `json:"store_id,omitempty"`
+	// NotifyURL and ReturnURL override the configured ones.
+	NotifyURL string `json:"-"`
+	ReturnURL string `json:"-"`
+}
+
+// AppPay returns the signed order string passed to the Alipay app SDK, see
+// AppOrderString.
+func (c *Client) AppPay(r *TradeOrderReq) (string, error) {
+	if r.ProductCode == "" {
+		r.ProductCode = ProductApp
+	}
+	req, err := c.newReq(methodAppPay, r, r.NotifyURL, "")
+	if err != nil {
+		return "", err
+	}
+	return AppOrderString(apikit.Params(structs.Map(req))), nil
+}
+
+// WapPay returns the URL the mobile browser should be redirected to.
+func (c *Client) WapPay(r *TradeOrderReq) (string, error) {
+	if r.ProductCode == "" {
+		r.ProductCode = ProductWap
+	}
+	return c.pageURL(methodWapPay, r, r.NotifyURL, r.ReturnURL)
+}
+
+// PagePay returns the URL the desktop browser should be redirected to.
+func (c *Client) PagePay(r *TradeOrderReq) (string, error) {
+	if r.ProductCode == "" {
+		r.ProductCode = ProductPage
+	}
+	return c.pageURL(methodPagePay, r, r.NotifyURL, r.ReturnURL)
+}
+
+// TradePrecreateReq ...
+type TradePrecreateReq struct {
+	OutTradeNo     string `json:"out_trade_no"`
+	TotalAmount    string `json:"total_amount"`
+	Subject        string `json:"subject"`
+	Body           string `json:"body,omitempty"`
+	OperatorID     string `json:"operator_id,omitempty"`
+	StoreID        string `json:"store_id,omitempty"`
+	TerminalID     string `json:"terminal_id,omitempty"`
+	TimeoutExpress string `json:"timeout_express,omitempty"`
+	NotifyURL      string `json:"-"`
+}
+
+// TradePrecreateResp ...
+type TradePrecreateResp struct {
+	RespCommon
+	OutTradeNo string `json:"out_trade_no"`
+	QRCode     string `json:"qr_code"`
+}
+
+// Precreate creates a trade to be paid by scanning QRCode, its result can
+// be waited by WaitPaid.
+func (c *Client) Precreate(r *TradePrecreateReq) (*TradePrecreateResp, error) {
+	res := new(TradePrecreateResp)
+	if err := c.do(methodPrecreate, r, r.NotifyURL, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// TradeQueryReq ...
+type TradeQueryReq struct {
+	OutTradeNo   string   `json:"out_trade_no,omitempty"`
+	TradeNo      string   `json:"trade_no,omitempty"`
+	QueryOptions []string `json:"query_options,omitempty"`
+}
+
+// TradeQueryResp ...
+type TradeQueryResp struct {
+	RespCommon
+	TradeNo        string      `json:"trade_no"`
+	OutTradeNo     string      `json:"out_trade_no"`
+	BuyerLogonID   string      `json:"buyer_logon_id"`
+	TradeStatus    TradeStatus `json:"trade_status"`
+	TotalAmount    string      `json:"total_amount"`
+	ReceiptAmount  string      `json:"receipt_amount"`
+	BuyerPayAmount string      `json:"buyer_pay_amount"`
+	PointAmount    string      `json:"point_amount"`
+	InvoiceAmount  string      `json:"invoice_amount"`
+	SendPayDate    string      `json:"send_pay_date"`
+	StoreID        string      `json:"store_id"`
+	TerminalID     string      `json:"terminal_id"`
+	StoreName      string      `json:"store_name"`
+	BuyerUserID    string      `json:"buyer_user_id"`
+}
+
+// Query ...
+func (c *Client) Query(r *TradeQueryReq) (*TradeQueryResp, error) {
+	res := new(TradeQueryResp)
+	if err := c.do(methodQuery, r, "", res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// TradeRefundReq refunds a trade by TradeNo or OutTradeNo, OutRequestNo
+// identifies a partial refund and is required for refunding a trade more
+// than once.
+type TradeRefundReq struct {
+	OutTradeNo   string `json:"out_trade_no,omitempty"`
+	TradeNo      string `json:"trade_no,omitempty"`
+	RefundAmount string `json:"refund_amount"`
+	RefundReason string `json:"refund_reason,omitempty"`
+	OutRequestNo string `json:"out_request_no,omitempty"`
+	OperatorID   string `json:"operator_id,omitempty"`
+	StoreID      string `json:"store_id,omitempty"`
+	TerminalID   string `json:"terminal_id,omitempty"`
+}
+
+// TradeRefundResp ...
+type TradeRefundResp struct {
+	RespCommon
+	TradeNo      string `json:"trade_no"`
+	OutTradeNo   string `json:"out_trade_no"`
+	BuyerLogonID string `json:"buyer_logon_id"`
+	FundChange   string `json:"fund_change"`
+	RefundFee    string `json:"refund_fee"`
+	GmtRefundPay string `json:"gmt_refund_pay"`
+	BuyerUserID  string `json:"buyer_user_id"`
+}
+
+// Refund ...
+func (c *Client) Refund(r *TradeRefundReq) (*TradeRefundResp, error) {
+	res := new(TradeRefundResp)
+	if err := c.do(methodRefund, r, "", res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// TradeRefundQueryReq queries a refund, OutRequestNo is the one of the
+// refund request, or OutTradeNo if it was not given.
+type TradeRefundQueryReq struct {
+	OutTradeNo   string `json:"out_trade_no,omitempty"`
+	TradeNo      string `json:"trade_no,omitempty"`
+	OutRequestNo string `json:"out_request_no"`
+}
+
+// TradeRefundQueryResp has an empty RefundAmount if the refund doesn't
+// exist.
+type TradeRefundQueryResp struct {
+	RespCommon
+	TradeNo      string `json:"trade_no"`
+	OutTradeNo   string `json:"out_trade_no"`
+	OutRequestNo string `json:"out_request_no"`
+	RefundReason string `json:"refund_reason"`
+	TotalAmount  string `json:"total_amount"`
+	RefundAmount string `json:"refund_amount"`
+	RefundStatus string `json:"refund_status"`
+	GmtRefundPay string `json:"gmt_refund_pay"`
+}
+
+// RefundQuery ...
+func (c *Client) RefundQuery(r *TradeRefundQueryReq) (*TradeRefundQueryResp, error) {
+	res := new(TradeRefundQueryResp)
+	if err := c.do(methodRefundQry, r, "", res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// TradeCloseReq ...
+type TradeCloseReq struct {
+	OutTradeNo string `json:"out_trade_no,omitempty"`
+	TradeNo    string `json:"trade_no,omitempty"`
+	OperatorID string `json:"operator_id,omitempty"`
+}
+
+// TradeCloseResp ...
+type TradeCloseResp struct {
+	RespCommon
+	TradeNo    string `json:"trade_no"`
+	OutTradeNo string `json:"out_trade_no"`
+}
+
+// Close closes an unpaid trade.
+func (c *Client) Close(r *TradeCloseReq) (*TradeCloseResp, error) {
+	res := new(TradeCloseResp)
+	if err := c.do(methodClose, r, "", res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// TradeCancelReq ...
+type TradeCancelReq struct {
+	OutTradeNo string `json:"out_trade_no,omitempty"`
+	TradeNo    string `json:"trade_no,omitempty"`
+}
+
+// TradeCancelResp tells whether to retry by RetryFlag, and whether the
+// trade was closed or refunded by Action.
+type TradeCancelResp struct {
+	RespCommon
+	TradeNo    string `json:"trade_no"`
+	OutTradeNo string `json:"out_trade_no"`
+	RetryFlag  string `json:"retry_flag"`
+	Action     string `json:"action"`
+}
+
+// Cancel closes an unpaid trade or refunds a paid one.
+func (c *Client) Cancel(r *TradeCancelReq) (*TradeCancelResp, error) {
+	res := new(TradeCancelResp)
+	if err := c.do(methodCancel, r, "", res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,163 @@
+package ali
+
+import "errors"
+
+// fund transfer methods
+const (
+	methodTransfer      = "alipay.fund.trans.uni.transfer"
+	methodTransferQuery = "alipay.fund.trans.common.query"
+)
+
+// ProductTransfer and SceneDirectTransfer are the defaults of transfers to
+// Alipay accounts.
+const (
+	ProductTransfer     = "TRANS_ACCOUNT_NO_PWD"
+	SceneDirectTransfer = "DIRECT_TRANSFER"
+)
+
+// payee identity types
+const (
+	IdentityUserID  = "ALIPAY_USER_ID"
+	IdentityLogonID = "ALIPAY_LOGON_ID"
+)
+
+// TransferStatus is the status of a transfer.
+type TransferStatus string
+
+// transfer statuses
+const (
+	TransferSuccess TransferStatus = "SUCCESS"
+	TransferDealing TransferStatus = "DEALING"
+	TransferWaitPay TransferStatus = "WAIT_PAY"
+	TransferClosed  TransferStatus = "CLOSED"
+	TransferFail    TransferStatus = "FAIL"
+	TransferRefund  TransferStatus = "REFUND"
+)
+
+// IsFinal tells whether the transfer won't change anymore.
+func (s TransferStatus) IsFinal() bool {
+	switch s {
+	case TransferSuccess, TransferClosed, TransferFail, TransferRefund:
+		return true
+	}
+	return false
+}
+
+// ErrNoOutBizNo is returned by Transfer without OutBizNo.
+var ErrNoOutBizNo = errors.New("ali: out_biz_no is required")
+
+// ErrTransferUnknown is returned by Transfer when its result is still
+// unknown after TransferQuery. The transfer may have been made, query it
+// again with the same OutBizNo and never retry it with a new one.
+var ErrTransferUnknown = errors.New("ali: transfer result unknown, query it by the same out_biz_no")
+
+// Participant identifies the payee, Name is required with IdentityLogonID.
+type Participant struct {
+	Identity     string `json:"identity"`
+	IdentityType string `json:"identity_type"`
+	Name         string `json:"name,omitempty"`
+}
+
+// TransferReq pays TransAmount yuan to PayeeInfo, ProductCode and BizScene
+// default to ProductTransfer and SceneDirectTransfer.
+type TransferReq struct {
+	OutBizNo       string      `json:"out_biz_no"`
+	TransAmount    string      `json:"trans_amount"`
+	ProductCode    string      `json:"product_code"`
+	BizScene       string      `json:"biz_scene"`
+	OrderTitle     string      `json:"order_title,omitempty"`
+	PayeeInfo      Participant `json:"payee_info"`
+	Remark         string      `json:"remark,omitempty"`
+	BusinessParams string      `json:"business_params,omitempty"`
+}
+
+// TransferResp ...
+type TransferResp struct {
+	RespCommon
+	OutBizNo       string         `json:"out_biz_no"`
+	OrderID        string         `json:"order_id"`
+	PayFundOrderID string         `json:"pay_fund_order_id"`
+	Status         TransferStatus `json:"status"`
+	TransDate      string         `json:"trans_date"`
+}
+
+// Transfer pays to an Alipay account. OutBizNo makes it idempotent: when
+// the result is unknown, e.g. on network errors or SYSTEM_ERROR, the
+// transfer is looked up by TransferQuery, and ErrTransferUnknown is
+// returned if it still can't be told, a new OutBizNo may pay twice.
+func (c *Client) Transfer(r *TransferReq) (*TransferResp, error) {
+	if r.OutBizNo == "" {
+		return nil, ErrNoOutBizNo
+	}
+	if r.ProductCode == "" {
+		r.ProductCode = ProductTransfer
+	}
+	if r.BizScene == "" {
+		r.BizScene = SceneDirectTransfer
+	}
+	res := new(TransferResp)
+	err := c.do(methodTransfer, r, "", res)
+	if err == nil {
+		return res, nil
+	}
+	if e, ok := err.(*Error); ok && e.Code != codeUnknown && e.SubCode != "SYSTEM_ERROR" {
+		return nil, err
+	}
+	q, err := c.TransferQuery(&TransferQueryReq{ProductCode: r.ProductCode, BizScene: r.BizScene, OutBizNo: r.OutBizNo})
+	if err != nil || q.Status == "" {
+		// ORDER_NOT_EXIST too, the transfer may not have been created yet.
+		return nil, ErrTransferUnknown
+	}
+	return &TransferResp{
+		RespCommon:     q.RespCommon,
+		OutBizNo:       r.OutBizNo,
+		OrderID:        q.OrderID,
+		PayFundOrderID: q.PayFundOrderID,
+		Status:         q.Status,
+		TransDate:      q.PayDate,
+	}, nil
+}
+
+// TransferQueryReq queries a transfer by OrderID, PayFundOrderID or
+// OutBizNo, ProductCode and BizScene must be given with OutBizNo and
+// default to ProductTransfer and SceneDirectTransfer.
+type TransferQueryReq struct {
+	ProductCode    string `json:"product_code,omitempty"`
+	BizScene       string `json:"biz_scene,omitempty"`
+	OutBizNo       string `json:"out_biz_no,omitempty"`
+	OrderID        string `json:"order_id,omitempty"`
+	PayFundOrderID string `json:"pay_fund_order_id,omitempty"`
+}
+
+// TransferQueryResp tells the reason of a failed transfer by ErrorCode and
+// FailReason.
+type TransferQueryResp struct {
+	RespCommon
+	OrderID        string         `json:"order_id"`
+	PayFundOrderID string         `json:"pay_fund_order_id"`
+	OutBizNo       string         `json:"out_biz_no"`
+	TransAmount    string         `json:"trans_amount"`
+	Status         TransferStatus `json:"status"`
+	PayDate        string         `json:"pay_date"`
+	ArrivalTimeEnd string         `json:"arrival_time_end"`
+	OrderFee       string         `json:"order_fee"`
+	ErrorCode      string         `json:"error_code"`
+	FailReason     string         `json:"fail_reason"`
+}
+
+// TransferQuery ...
+func (c *Client) TransferQuery(r *TransferQueryReq) (*TransferQueryResp, error) {
+	if r.OutBizNo != "" {
+		if r.ProductCode == "" {
+			r.ProductCode = ProductTransfer
+		}
+		if r.BizScene == "" {
+			r.BizScene = SceneDirectTransfer
+		}
+	}
+	res := new(TransferQueryResp)
+	if err := c.do(methodTransferQuery, r, "", res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,113 @@
+package ali
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// openServer answers each method with the node of resp, signed by the key
+// of the returned Client when its code is 10000.
+func openServer(t *testing.T, resp map[string]string) (*Client, *httptest.Server, map[string][]string) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bizs := map[string][]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := r.FormValue("method")
+		bizs[method] = append(bizs[method], r.FormValue("biz_content"))
+		node, ok := resp[method]
+		if !ok {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		name := strings.Replace(method, ".", "_", -1) + "_response"
+		body := map[string]interface{}{name: json.RawMessage(node)}
+		if strings.Contains(node, `"10000"`) {
+			sign, err := signRSA(key, crypto.SHA256, []byte(node))
+			if err != nil {
+				t.Error(err)
+			}
+			body["sign"] = sign
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(nil, Config{
+		AppID:           "a1",
+		PrivateKey:      pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		AlipayPublicKey: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}),
+		Gateway:         srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, srv, bizs
+}
+
+func TestTransfer(t *testing.T) {
+	const (
+		success     = `{"code":"10000","msg":"Success","out_biz_no":"b1","order_id":"o1","status":"SUCCESS"}`
+		systemError = `{"code":"40004","msg":"Business Failed","sub_code":"SYSTEM_ERROR","sub_msg":"系统繁忙"}`
+		notExist    = `{"code":"40004","msg":"Business Failed","sub_code":"ORDER_NOT_EXIST","sub_msg":"转账订单不存在"}`
+		payeeError  = `{"code":"40004","msg":"Business Failed","sub_code":"PAYEE_NOT_EXIST","sub_msg":"收款账号不存在"}`
+		querySucc   = `{"code":"10000","msg":"Success","out_biz_no":"b1","order_id":"o1","status":"SUCCESS","pay_date":"2020-01-02 10:00:00"}`
+	)
+	tests := []struct {
+		name          string
+		transfer      string
+		query         string
+		queried       bool
+		want          error
+		wantOrderID   string
+		wantErrorCode string
+	}{
+		{"success", success, "", false, nil, "o1", ""},
+		{"failed", payeeError, "", false, nil, "", "PAYEE_NOT_EXIST"},
+		{"system error then made", systemError, querySucc, true, nil, "o1", ""},
+		{"system error then not found", systemError, notExist, true, ErrTransferUnknown, "", ""},
+		{"network error then made", "", querySucc, true, nil, "o1", ""},
+		{"network error then unknown", "", "", true, ErrTransferUnknown, "", ""},
+	}
+	for _, tt := range tests {
+		resp := map[string]string{}
+		if tt.transfer != "" {
+			resp[methodTransfer] = tt.transfer
+		}
+		if tt.query != "" {
+			resp[methodTransferQuery] = tt.query
+		}
+		c, srv, bizs := openServer(t, resp)
+		res, err := c.Transfer(&TransferReq{
+			OutBizNo:    "b1",
+			TransAmount: "1.00",
+			PayeeInfo:   Participant{Identity: "2088", IdentityType: IdentityUserID},
+		})
+		srv.Close()
+
+		switch e, _ := err.(*Error); {
+		case tt.wantErrorCode != "":
+			if e == nil || e.SubCode != tt.wantErrorCode {
+				t.Errorf("%s: got %v", tt.name, err)
+			}
+		case err != tt.want:
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		case err == nil && (res.OrderID != tt.wantOrderID || res.Status != TransferSuccess || res.OutBizNo != "b1"):
+			t.Errorf("%s: res = %+v", tt.name, res)
+		}
+		if q := bizs[methodTransferQuery]; (len(q) != 0) != tt.queried || len(q) != 0 && !strings.Contains(q[0], `"out_biz_no":"b1"`) {
+			t.Errorf("%s: queries = %v", tt.name, q)
+		}
+	}
+}
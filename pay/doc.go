@@ -30,21 +30,28 @@ Example:
 	r.PaymentType = "1"
 	r.TotalFee = "0.01"
 	r.SellerEmail = ""
-	r.Sign = string(s.Sign(r, []byte(``)))
+	sign, err := s.Sign(r, []byte(``))
+	r.Sign = string(sign)
 	fmt.Println(s.PayURL(r))
 
+	c, err := union.NewClient(&http.Client{}, union.Config{
+		SignPFX:         pfx, // acp_prod_sign.pfx
+		SignPFXPassword: "",
+		VerifyCert:      cer, // acp_prod_verify_sign.cer
+	})
 	r := union.DefaultOrderReq()
-	r.CertID = "" // openssl pkcs12 -in acp_prod_sign.pfx -clcerts -nokeys -out key.cert
 	r.BackURL = "http://127.0.0.1"
 	r.MerID = ""
 	r.OrderID = strconv.FormatInt(time.Now().UnixNano(), 10)
 	r.FrontURL = "http://127.0.0.1"
 	r.OrderDesc = "desc"
-	secret := `` // openssl pkcs12 -in acp_prod_sign.pfx -nocerts -nodes -out key.pem
-	s := union.Sign(req, []byte(secret))
-	r.Signature = s
-	resp := union.AppConsume(r)
-	public := `` // acp_prod_verify_sign.cer
-	fmt.Println(union.Verify(resp, []byte(public), []byte(resp.Signature)))
+	resp, err := c.AppConsume(r)
+	fmt.Println(resp, err)
+
+Ali.Sign returns ([]byte, error), a bad key is an error instead of a panic. It
+signs the sorted params as k=v for RSA, where k="v" was signed before, and
+the MD5 sign is lowercase hex instead of the raw digest. The quoted order
+string of legacy mobile.securitypay.pay payments is built by
+Ali.MobileOrderString.
 */
 package pay
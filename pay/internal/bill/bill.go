@@ -0,0 +1,85 @@
+// Package bill reads the typed fields of the bills of the pay services.
+package bill
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Record reads typed fields of a bill record by their column names, the
+// first error is kept in Err and later reads return zero.
+type Record struct {
+	M   map[string]string
+	Err error
+}
+
+// Str returns the field of the first of keys present.
+func (r *Record) Str(keys ...string) string {
+	for _, k := range keys {
+		if v, ok := r.M[k]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// Int reads an integer field.
+func (r *Record) Int(keys ...string) int {
+	s := r.Str(keys...)
+	if s == "" || r.Err != nil {
+		return 0
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		r.Err = err
+	}
+	return n
+}
+
+// Fen reads an amount in yuan as fen.
+func (r *Record) Fen(keys ...string) int64 {
+	s := r.Str(keys...)
+	if s == "" || r.Err != nil {
+		return 0
+	}
+	n, err := Fen(s)
+	if err != nil {
+		r.Err = err
+	}
+	return n
+}
+
+// Fen converts an amount in yuan to fen, fees of more than 2 decimals are
+// rounded half away from zero.
+func Fen(yuan string) (int64, error) {
+	s := strings.TrimSpace(yuan)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	y, cents := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		y, cents = s[:i], s[i+1:]
+	}
+	if y+cents == "" {
+		return 0, fmt.Errorf("pay: invalid amount %q", yuan)
+	}
+	var round string
+	if len(cents) > 2 {
+		cents, round = cents[:2], cents[2:]
+	}
+	cents += strings.Repeat("0", 2-len(cents))
+	if y == "" {
+		y = "0"
+	}
+	n, err := strconv.ParseInt(y+cents, 10, 64)
+	if err != nil || strings.Trim(round, "0123456789") != "" {
+		return 0, fmt.Errorf("pay: invalid amount %q", yuan)
+	}
+	if round != "" && round[0] >= '5' {
+		n++
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
@@ -0,0 +1,51 @@
+package bill
+
+import "testing"
+
+func TestFen(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"1", 100, true},
+		{"1.5", 150, true},
+		{"0.01", 1, true},
+		{" 2.00 ", 200, true},
+		{"-0.00300", 0, true},
+		{"0.00700", 1, true},
+		{"0.00500", 1, true},
+		{"-0.00600", -1, true},
+		{"0.0049", 0, true},
+		{"+12.30", 1230, true},
+		{"-3.1", -310, true},
+		{".5", 50, true},
+		{"", 0, false},
+		{".", 0, false},
+		{"1.2.3", 0, false},
+		{"1.00x", 0, false},
+		{"abc", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := Fen(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Fen(%q) = %d, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	r := &Record{M: map[string]string{"a": "1", "b": "1.00", "c": "x"}}
+	if r.Str("z", "a") != "1" || r.Int("a") != 1 || r.Fen("z", "b") != 100 || r.Fen("z") != 0 {
+		t.Fatalf("unexpected reads, err %v", r.Err)
+	}
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if r.Int("c"); r.Err == nil {
+		t.Fatal("want error for a bad int")
+	}
+	if r.Fen("b") != 0 {
+		t.Error("reads after an error must return zero")
+	}
+}
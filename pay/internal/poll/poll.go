@@ -0,0 +1,43 @@
+// Package poll waits for the result of a barcode payment, which is polled
+// with backoff and canceled when it times out.
+package poll
+
+import "time"
+
+const (
+	interval    = 2 * time.Second
+	maxInterval = 10 * time.Second
+	maxRetry    = 5
+)
+
+// Until calls f with backoff, starting after interval, until it returns
+// true or deadline passes, and reports whether it returned true.
+func Until(deadline time.Time, f func() bool) bool {
+	for d := interval; time.Now().Before(deadline); d = d * 3 / 2 {
+		if d > maxInterval {
+			d = maxInterval
+		}
+		if left := time.Until(deadline); d > left {
+			d = left
+		}
+		time.Sleep(d)
+		if f() {
+			return true
+		}
+	}
+	return false
+}
+
+// Retry calls f up to maxRetry times every interval until it returns true,
+// and reports whether it did.
+func Retry(f func() bool) bool {
+	for i := 0; i < maxRetry; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		if f() {
+			return true
+		}
+	}
+	return false
+}
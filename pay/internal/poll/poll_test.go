@@ -0,0 +1,30 @@
+package poll
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUntilPastDeadline(t *testing.T) {
+	if Until(time.Now(), func() bool { t.Error("f called after deadline"); return true }) {
+		t.Error("got true")
+	}
+}
+
+func TestUntil(t *testing.T) {
+	n := 0
+	start := time.Now()
+	if !Until(start.Add(time.Minute), func() bool { n++; return true }) || n != 1 {
+		t.Errorf("got %d calls", n)
+	}
+	if d := time.Since(start); d < interval {
+		t.Errorf("first call after %v, want %v", d, interval)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	n := 0
+	if !Retry(func() bool { n++; return true }) || n != 1 {
+		t.Errorf("got %d calls", n)
+	}
+}
@@ -0,0 +1,113 @@
+package union
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// LoadPFX returns the private key and the certId of acp_*_sign.pfx.
+func LoadPFX(pfx []byte, password string) (*rsa.PrivateKey, string, error) {
+	key, cert, err := pkcs12.Decode(pfx, password)
+	if err != nil {
+		return nil, "", err
+	}
+	k, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, "", errors.New("union: sign key is not RSA")
+	}
+	return k, cert.SerialNumber.String(), nil
+}
+
+// parseCert accepts a PEM or DER certificate, e.g. acp_*_verify_sign.cer.
+func parseCert(cer []byte) (*x509.Certificate, error) {
+	if p, _ := pem.Decode(cer); p != nil {
+		cer = p.Bytes
+	}
+	return x509.ParseCertificate(cer)
+}
+
+// CertID returns the certId of a PEM or DER certificate, which is its
+// decimal serial.
+func CertID(cer []byte) (string, error) {
+	c, err := parseCert(cer)
+	if err != nil {
+		return "", err
+	}
+	return c.SerialNumber.String(), nil
+}
+
+// AddVerifyCert adds a PEM or DER certificate verifying the responses and
+// notifications signed with its certId.
+func (c *Client) AddVerifyCert(cer []byte) error {
+	cert, err := parseCert(cer)
+	if err != nil {
+		return err
+	}
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("union: verify cert is not RSA")
+	}
+	c.mu.Lock()
+	c.certs[cert.SerialNumber.String()] = pub
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Client) verifyKey(certID string) (*rsa.PublicKey, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if pub, ok := c.certs[certID]; ok {
+		return pub, nil
+	}
+	return nil, errors.New("union: unknown verify certId " + certID)
+}
+
+// unionPayCN is the organization in the CN of signPubKeyCert.
+const unionPayCN = "中国银联股份有限公司"
+
+func certPool(cer []byte) (*x509.CertPool, error) {
+	c, err := parseCert(cer)
+	if err != nil {
+		return nil, err
+	}
+	p := x509.NewCertPool()
+	p.AddCert(c)
+	return p, nil
+}
+
+// signPubKey returns the key of a signPubKeyCert after validating it
+// against the middle and root certs, and its CN, which is like
+// 043@Z12@中国银联股份有限公司@00000001.
+func (c *Client) signPubKey(cer string) (*rsa.PublicKey, error) {
+	if c.roots == nil {
+		return nil, errors.New("union: root cert is not configured")
+	}
+	cert, err := parseCert([]byte(cer))
+	if err != nil {
+		return nil, err
+	}
+	opts := x509.VerifyOptions{
+		Roots:         c.roots,
+		Intermediates: c.middles,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return nil, err
+	}
+	if !c.skipCN {
+		cn := strings.Split(cert.Subject.CommonName, "@")
+		if len(cn) < 3 || cn[2] != unionPayCN {
+			return nil, errors.New("union: signPubKeyCert is not issued to " + unionPayCN)
+		}
+	}
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("union: signPubKeyCert is not RSA")
+	}
+	return pub, nil
+}
@@ -0,0 +1,269 @@
+package union
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/douglarek/apikit"
+)
+
+// gateways of the production and test environments, any other one can be
+// configured as a custom environment.
+const (
+	GatewayProd = "https://gateway.95516.com"
+	GatewayTest = "https://gateway.test.95516.com"
+)
+
+const appTransReqPath = "/gateway/api/appTransReq.do"
+
+// Config is the configuration of a Client.
+type Config struct {
+	// Gateway defaults to GatewayProd.
+	Gateway string
+	// SignPFX is the content of acp_*_sign.pfx, the certId is derived from
+	// its certificate.
+	SignPFX         []byte
+	SignPFXPassword string
+	// SignKey is the PEM encoded PKCS#1 or PKCS#8 merchant private key, and
+	// CertID the serial of its certificate, they are used without SignPFX.
+	SignKey []byte
+	CertID  string
+	// VerifyCert is the certificate verifying 5.0.0 responses and
+	// notifications, i.e. acp_*_verify_sign.cer, more can be added by
+	// AddVerifyCert.
+	VerifyCert []byte
+	// MiddleCert and RootCert, i.e. acp_*_middle.cer and acp_*_root.cer,
+	// validate the signPubKeyCert of 5.1.0 responses and notifications.
+	MiddleCert []byte
+	RootCert   []byte
+	// SkipCNCheck accepts signPubKeyCert not issued to 中国银联股份有限公司,
+	// e.g. in the test environment.
+	SkipCNCheck bool
+}
+
+// Client handles communication with the Union Pay gateway.
+type Client struct {
+	client  *apikit.Client
+	gateway string
+	key     *rsa.PrivateKey
+	certID  string
+
+	mu    sync.RWMutex
+	certs map[string]*rsa.PublicKey
+
+	roots   *x509.CertPool
+	middles *x509.CertPool
+	skipCN  bool
+}
+
+// NewClient makes a Union Pay client.
+func NewClient(httpClient *http.Client, c Config) (*Client, error) {
+	if c.Gateway == "" {
+		c.Gateway = GatewayProd
+	}
+	cl := &Client{
+		client:  apikit.NewClient(httpClient),
+		gateway: strings.TrimSuffix(c.Gateway, "/"),
+		certID:  c.CertID,
+		certs:   map[string]*rsa.PublicKey{},
+		skipCN:  c.SkipCNCheck,
+	}
+	var err error
+	if len(c.SignPFX) != 0 {
+		if cl.key, cl.certID, err = LoadPFX(c.SignPFX, c.SignPFXPassword); err != nil {
+			return nil, err
+		}
+	} else {
+		p, _ := pem.Decode(c.SignKey)
+		if p == nil {
+			return nil, errors.New("union: failed to parse sign key PEM")
+		}
+		if cl.key, err = parsePrivateKey(p.Bytes); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.VerifyCert) != 0 {
+		if err := cl.AddVerifyCert(c.VerifyCert); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.RootCert) != 0 {
+		if cl.roots, err = certPool(c.RootCert); err != nil {
+			return nil, err
+		}
+		if cl.middles, err = certPool(c.MiddleCert); err != nil {
+			return nil, err
+		}
+	}
+	return cl, nil
+}
+
+func parsePrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	rk, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("union: sign key is not RSA")
+	}
+	return rk, nil
+}
+
+// Sign signs the params of s with the merchant key, by the algorithm of
+// its version.
+func (c *Client) Sign(s interface{}) (string, error) {
+	return sign(c.key, s)
+}
+
+// Verify verifies sign of the params of s, a struct or the
+// map[string]string of a response or notification, with its validated
+// signPubKeyCert for version 5.1.0, or the verify cert of its certId for
+// 5.0.0.
+func (c *Client) Verify(s interface{}, sign string) error {
+	m := values(s)
+	var pub *rsa.PublicKey
+	var err error
+	if m["version"] == Version510 {
+		pub, err = c.signPubKey(m["signPubKeyCert"])
+	} else {
+		pub, err = c.verifyKey(m["certId"])
+	}
+	if err != nil {
+		return err
+	}
+	return verify(pub, s, sign)
+}
+
+// AppConsume sets CertID and Signature of r and requests a tn.
+func (c *Client) AppConsume(r *OrderReq) (*OrderResp, error) {
+	res := new(OrderResp)
+	if err := c.send(appTransReqPath, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// request is a request signed by send.
+type request interface {
+	setCertID(certID string)
+	setSignature(sign string)
+}
+
+func (r *OrderReq) setCertID(certID string)  { r.CertID = certID }
+func (r *OrderReq) setSignature(sign string) { r.Signature = sign }
+
+// send sets certId and signature of r and calls do.
+func (c *Client) send(path string, r request, v interface{}) error {
+	r.setCertID(c.certID)
+	sign, err := c.Sign(r)
+	if err != nil {
+		return err
+	}
+	r.setSignature(sign)
+	return c.do(path, r, v)
+}
+
+// Error is a response whose respCode is not 00.
+type Error struct {
+	RespCode string
+	RespMsg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("union: %s %s", e.RespCode, e.RespMsg)
+}
+
+// do posts the signed r to path, verifies the response and decodes it into
+// v.
+func (c *Client) do(path string, r, v interface{}) error {
+	m, err := c.post(path, r)
+	if err != nil {
+		return err
+	}
+	if sign := m["signature"]; sign != "" {
+		if err := c.Verify(m, sign); err != nil {
+			return err
+		}
+	} else if m["respCode"] == "00" {
+		// only failed responses may be unsigned.
+		return errors.New("union: response is not signed")
+	}
+	return unmarshal(m, v)
+}
+
+func (c *Client) post(path string, r interface{}) (map[string]string, error) {
+	req, err := c.client.NewRequest("POST", c.gateway+path, r)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := c.client.Do(req, &buf); err != nil {
+		return nil, err
+	}
+	return parseResp(buf.String())
+}
+
+// parseResp parses a k=v&k=v response, values may contain "=" and nested
+// fields wrapped by {}, which may contain "&".
+func parseResp(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("union: empty response")
+	}
+	m := map[string]string{}
+	depth, start := 0, 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			switch s[i] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth < 0 {
+					return nil, errors.New("union: unbalanced {} in response")
+				}
+			}
+			if s[i] != '&' || depth > 0 {
+				continue
+			}
+		}
+		kv := s[start:i]
+		start = i + 1
+		if kv == "" {
+			continue
+		}
+		j := strings.IndexByte(kv, '=')
+		if j <= 0 {
+			return nil, fmt.Errorf("union: malformed response field %q", kv)
+		}
+		m[kv[:j]] = kv[j+1:]
+	}
+	if depth != 0 {
+		return nil, errors.New("union: unbalanced {} in response")
+	}
+	return m, nil
+}
+
+// unmarshal decodes m into v, a respCode not 00 is returned as *Error.
+func unmarshal(m map[string]string, v interface{}) error {
+	if m["respCode"] != "00" {
+		return &Error{RespCode: m["respCode"], RespMsg: m["respMsg"]}
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,126 @@
+package union
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseResp(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]string
+	}{
+		{"a=1&b=2", map[string]string{"a": "1", "b": "2"}},
+		{" a=1&&b=2\n", map[string]string{"a": "1", "b": "2"}},
+		{"tn=x=y&b=", map[string]string{"tn": "x=y", "b": ""}},
+		{"r={a=1&b={c=2&d=3}}&e=4", map[string]string{"r": "{a=1&b={c=2&d=3}}", "e": "4"}},
+		{"", nil},
+		{"a", nil},
+		{"=1", nil},
+		{"a={&b=2", nil},
+		{"a=}{", nil},
+		{"a=}&b={", nil},
+	}
+	for _, tt := range tests {
+		got, err := parseResp(tt.in)
+		if (err == nil) != (tt.want != nil) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseResp(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	res := new(OrderResp)
+	if err := unmarshal(map[string]string{"respCode": "00", "tn": "t1", "orderId": "o1"}, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Tn != "t1" || res.OrderID != "o1" {
+		t.Errorf("res = %+v", res)
+	}
+	err := unmarshal(map[string]string{"respCode": "12", "respMsg": "重复交易"}, new(OrderResp))
+	if e, ok := err.(*Error); !ok || e.RespCode != "12" || e.RespMsg != "重复交易" {
+		t.Errorf("got %v", err)
+	}
+}
+
+// testClient returns a Client whose sign key also signs the responses, and
+// the certId of its verify cert.
+func testClient(t *testing.T, gateway string) (*Client, string) {
+	k, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(69042905377),
+		Subject:      pkix.Name{CommonName: "verify"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &k.PublicKey, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(nil, Config{
+		Gateway:    gateway,
+		SignKey:    pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}),
+		CertID:     "69042905377",
+		VerifyCert: der,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, "69042905377"
+}
+
+func TestAppConsume(t *testing.T) {
+	var resp map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var vals []string
+		for k, v := range resp {
+			vals = append(vals, k+"="+v)
+		}
+		w.Write([]byte(strings.Join(vals, "&")))
+	}))
+	defer srv.Close()
+	c, certID := testClient(t, srv.URL)
+
+	resp = map[string]string{"version": Version500, "certId": certID, "respCode": "00", "tn": "t1"}
+	sig, err := c.Sign(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp["signature"] = sig
+	res, err := c.AppConsume(DefaultOrderReq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Tn != "t1" {
+		t.Errorf("res = %+v", res)
+	}
+
+	resp["tn"] = "t2"
+	if _, err := c.AppConsume(DefaultOrderReq()); err == nil {
+		t.Error("tampered response accepted")
+	}
+
+	resp = map[string]string{"version": Version500, "certId": certID, "respCode": "00", "tn": "t1"}
+	if _, err := c.AppConsume(DefaultOrderReq()); err == nil {
+		t.Error("unsigned success response accepted")
+	}
+
+	resp = map[string]string{"respCode": "12", "respMsg": "dup"}
+	_, err = c.AppConsume(DefaultOrderReq())
+	if e, ok := err.(*Error); !ok || e.RespCode != "12" {
+		t.Errorf("got %v", err)
+	}
+}
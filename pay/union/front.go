@@ -0,0 +1,67 @@
+package union
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"sort"
+)
+
+const frontTransReqPath = "/gateway/api/frontTransReq.do"
+
+// ChannelDesktop is the channelType of web and WAP payments.
+const ChannelDesktop = "07"
+
+// FrontForm sets CertID and Signature of r, whose ChannelType must be
+// ChannelDesktop, and returns the action URL and the fields of the form
+// the browser should post.
+func (c *Client) FrontForm(r *OrderReq) (string, map[string]string, error) {
+	if r.ChannelType != ChannelDesktop {
+		return "", nil, errors.New("union: channelType of front form must be " + ChannelDesktop)
+	}
+	r.setCertID(c.certID)
+	sign, err := c.Sign(r)
+	if err != nil {
+		return "", nil, err
+	}
+	r.setSignature(sign)
+	return c.gateway + frontTransReqPath, values(r), nil
+}
+
+var frontForm = template.Must(template.New("form").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="UTF-8"></head>
+<body onload="document.forms[0].submit()">
+<form action="{{.Action}}" method="post">
+{{range .Fields}}<input type="hidden" name="{{.Name}}" value="{{.Value}}">
+{{end}}</form>
+</body>
+</html>
+`))
+
+type formField struct {
+	Name, Value string
+}
+
+// FrontFormHTML is like FrontForm but returns an escaped HTML page
+// submitting the form on load.
+func (c *Client) FrontFormHTML(r *OrderReq) (string, error) {
+	action, m, err := c.FrontForm(r)
+	if err != nil {
+		return "", err
+	}
+	fields := make([]formField, 0, len(m))
+	for k, v := range m {
+		fields = append(fields, formField{k, v})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	var buf bytes.Buffer
+	err = frontForm.Execute(&buf, struct {
+		Action string
+		Fields []formField
+	}{action, fields})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
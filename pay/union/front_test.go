@@ -0,0 +1,51 @@
+package union
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFrontForm(t *testing.T) {
+	c, _ := testClient(t, "https://gateway.test.95516.com")
+
+	r := DefaultOrderReq()
+	if _, _, err := c.FrontForm(r); err == nil {
+		t.Error("app channelType accepted")
+	}
+
+	r.ChannelType = ChannelDesktop
+	r.OrderID, r.TxnAmt = "o1", "100"
+	r.FrontURL = `https://m.test/back?a=1&b="x"`
+	r.OrderDesc = `<script>alert("x")</script>`
+	action, fields, err := c.FrontForm(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action != "https://gateway.test.95516.com"+frontTransReqPath {
+		t.Errorf("action = %s", action)
+	}
+	if err := c.Verify(fields, fields["signature"]); err != nil {
+		t.Errorf("fields do not verify: %v", err)
+	}
+
+	page, err := c.FrontFormHTML(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(page, "<script>") || strings.Contains(page, `b="x"`) {
+		t.Errorf("unescaped page %s", page)
+	}
+	inputs := regexp.MustCompile(`<input type="hidden" name="(\w+)" value="([^"]*)">`).FindAllStringSubmatch(page, -1)
+	got := make(map[string]string, len(inputs))
+	for _, m := range inputs {
+		got[m[1]] = html.UnescapeString(m[2])
+	}
+	if len(got) != len(fields) || got["frontUrl"] != r.FrontURL || got["orderDesc"] != r.OrderDesc {
+		t.Errorf("page fields = %v, want %v", got, fields)
+	}
+	if err := c.Verify(got, got["signature"]); err != nil {
+		t.Errorf("page fields do not verify: %v", err)
+	}
+}
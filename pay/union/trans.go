@@ -0,0 +1,201 @@
+package union
+
+import (
+	"errors"
+	"net/http"
+)
+
+const (
+	queryTransPath   = "/gateway/api/queryTrans.do"
+	backTransReqPath = "/gateway/api/backTransReq.do"
+)
+
+// txn types
+const (
+	TxnQuery   = "00"
+	TxnConsume = "01"
+	TxnRefund  = "04"
+	TxnCancel  = "31"
+)
+
+// QueryReq queries a transaction by the OrderID and TxnTime it was sent
+// with.
+type QueryReq struct {
+	Version    string `structs:"version" json:"version"`
+	Encoding   string `structs:"encoding" json:"encoding"`
+	CertID     string `structs:"certId" json:"certId"`
+	SignMethod string `structs:"signMethod" json:"signMethod"`
+	Signature  string `structs:"signature" json:"signature"`
+	TxnType    string `structs:"txnType" json:"txnType"`
+	TxnSubType string `structs:"txnSubType" json:"txnSubType"`
+	BizType    string `structs:"bizType" json:"bizType"`
+	AccessType string `structs:"accessType" json:"accessType"`
+	MerID      string `structs:"merId" json:"merId"`
+	OrderID    string `structs:"orderId" json:"orderId"`
+	TxnTime    string `structs:"txnTime" json:"txnTime"`
+}
+
+func (r *QueryReq) setCertID(certID string)  { r.CertID = certID }
+func (r *QueryReq) setSignature(sign string) { r.Signature = sign }
+
+// DefaultQueryReq ...
+func DefaultQueryReq() *QueryReq {
+	return &QueryReq{
+		Version:    Version500,
+		Encoding:   "UTF-8",
+		SignMethod: "01",
+		TxnType:    TxnQuery,
+		TxnSubType: "00",
+		BizType:    "000000",
+		AccessType: "0",
+	}
+}
+
+// QueryResp tells the result of the transaction by OrigRespCode, 00 is
+// success and 03, 04 and 05 are pending.
+type QueryResp struct {
+	QueryReq
+	RespCode           string `structs:"respCode" json:"respCode"`
+	RespMsg            string `structs:"respMsg" json:"respMsg"`
+	OrigRespCode       string `structs:"origRespCode" json:"origRespCode"`
+	OrigRespMsg        string `structs:"origRespMsg" json:"origRespMsg"`
+	QueryID            string `structs:"queryId" json:"queryId"`
+	TxnAmt             string `structs:"txnAmt" json:"txnAmt"`
+	CurrencyCode       string `structs:"currencyCode" json:"currencyCode"`
+	TraceNo            string `structs:"traceNo" json:"traceNo"`
+	TraceTime          string `structs:"traceTime" json:"traceTime"`
+	SettleAmt          string `structs:"settleAmt" json:"settleAmt"`
+	SettleCurrencyCode string `structs:"settleCurrencyCode" json:"settleCurrencyCode"`
+	SettleDate         string `structs:"settleDate" json:"settleDate"`
+	AccNo              string `structs:"accNo" json:"accNo"`
+	PayType            string `structs:"payType" json:"payType"`
+	PayCardType        string `structs:"payCardType" json:"payCardType"`
+	ReqReserved        string `structs:"reqReserved" json:"reqReserved"`
+	SignPubKeyCert     string `structs:"signPubKeyCert" json:"signPubKeyCert"`
+}
+
+// IsPending tells whether the transaction is still being processed.
+func (r *QueryResp) IsPending() bool {
+	return r.OrigRespCode == "03" || r.OrigRespCode == "04" || r.OrigRespCode == "05"
+}
+
+// Query sets CertID and Signature of r and queries the transaction.
+func (c *Client) Query(r *QueryReq) (*QueryResp, error) {
+	res := new(QueryResp)
+	if err := c.send(queryTransPath, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// BackTransReq cancels or refunds the consume of OrigQryID, its OrderID is
+// a new one identifying this transaction.
+type BackTransReq struct {
+	Version     string `structs:"version" json:"version"`
+	Encoding    string `structs:"encoding" json:"encoding"`
+	CertID      string `structs:"certId" json:"certId"`
+	SignMethod  string `structs:"signMethod" json:"signMethod"`
+	Signature   string `structs:"signature" json:"signature"`
+	TxnType     string `structs:"txnType" json:"txnType"`
+	TxnSubType  string `structs:"txnSubType" json:"txnSubType"`
+	BizType     string `structs:"bizType" json:"bizType"`
+	AccessType  string `structs:"accessType" json:"accessType"`
+	ChannelType string `structs:"channelType" json:"channelType"`
+	MerID       string `structs:"merId" json:"merId"`
+	OrderID     string `structs:"orderId" json:"orderId"`
+	OrigQryID   string `structs:"origQryId" json:"origQryId"`
+	TxnTime     string `structs:"txnTime" json:"txnTime"`
+	TxnAmt      string `structs:"txnAmt" json:"txnAmt"`
+	BackURL     string `structs:"backUrl" json:"backUrl"`
+	ReqReserved string `structs:"reqReserved" json:"reqReserved"`
+}
+
+func (r *BackTransReq) setCertID(certID string)  { r.CertID = certID }
+func (r *BackTransReq) setSignature(sign string) { r.Signature = sign }
+
+func defaultBackTransReq(txnType string) *BackTransReq {
+	return &BackTransReq{
+		Version:     Version500,
+		Encoding:    "UTF-8",
+		SignMethod:  "01",
+		TxnType:     txnType,
+		TxnSubType:  "00",
+		BizType:     "000201",
+		AccessType:  "0",
+		ChannelType: "08",
+		TxnTime:     txnTime(),
+	}
+}
+
+// DefaultCancelReq returns a consume cancel request, which must be sent on
+// the day of the consume with its full TxnAmt.
+func DefaultCancelReq() *BackTransReq {
+	return defaultBackTransReq(TxnCancel)
+}
+
+// DefaultRefundReq ...
+func DefaultRefundReq() *BackTransReq {
+	return defaultBackTransReq(TxnRefund)
+}
+
+// BackTransResp only tells the request is accepted, the result is notified
+// to BackURL or can be queried by Query.
+type BackTransResp struct {
+	BackTransReq
+	RespCode       string `structs:"respCode" json:"respCode"`
+	RespMsg        string `structs:"respMsg" json:"respMsg"`
+	QueryID        string `structs:"queryId" json:"queryId"`
+	SignPubKeyCert string `structs:"signPubKeyCert" json:"signPubKeyCert"`
+}
+
+// Cancel sets CertID and Signature of r and cancels a consume.
+func (c *Client) Cancel(r *BackTransReq) (*BackTransResp, error) {
+	if r.TxnType != TxnCancel {
+		return nil, errors.New("union: txnType of cancel must be " + TxnCancel)
+	}
+	return c.backTrans(r)
+}
+
+// Refund sets CertID and Signature of r and refunds a consume.
+func (c *Client) Refund(r *BackTransReq) (*BackTransResp, error) {
+	if r.TxnType != TxnRefund {
+		return nil, errors.New("union: txnType of refund must be " + TxnRefund)
+	}
+	return c.backTrans(r)
+}
+
+func (c *Client) backTrans(r *BackTransReq) (*BackTransResp, error) {
+	res := new(BackTransResp)
+	if err := c.send(backTransReqPath, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// BackTransNotifyReq is the result notification of a cancel or refund.
+type BackTransNotifyReq struct {
+	BackTransResp
+	TraceNo            string `structs:"traceNo" json:"traceNo"`
+	TraceTime          string `structs:"traceTime" json:"traceTime"`
+	SettleAmt          string `structs:"settleAmt" json:"settleAmt"`
+	SettleCurrencyCode string `structs:"settleCurrencyCode" json:"settleCurrencyCode"`
+	SettleDate         string `structs:"settleDate" json:"settleDate"`
+}
+
+// ParseNotify verifies the notification posted to BackURL and decodes it
+// into v, a NotifyReq for consumes or a BackTransNotifyReq for cancels and
+// refunds. A respCode not 00 is returned as *Error, the gateway expects a
+// 200 response once it is handled.
+func (c *Client) ParseNotify(req *http.Request, v interface{}) error {
+	if err := req.ParseForm(); err != nil {
+		return err
+	}
+	m := make(map[string]string, len(req.PostForm))
+	for k := range req.PostForm {
+		m[k] = req.PostForm.Get(k)
+	}
+	if err := c.Verify(m, m["signature"]); err != nil {
+		return err
+	}
+	return unmarshal(m, v)
+}
@@ -0,0 +1,156 @@
+package union
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// signed returns m signed by c as a 5.0.0 response of certID.
+func signed(t *testing.T, c *Client, certID string, m map[string]string) map[string]string {
+	m["version"], m["certId"] = Version500, certID
+	sig, err := c.Sign(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m["signature"] = sig
+	return m
+}
+
+// transServer serves resp to the requests of path, which must be signed.
+func transServer(t *testing.T, path string, c **Client, resp *map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			t.Errorf("path = %s, want %s", r.URL.Path, path)
+		}
+		r.ParseForm()
+		m := map[string]string{}
+		for k := range r.PostForm {
+			m[k] = r.PostForm.Get(k)
+		}
+		if err := (*c).Verify(m, m["signature"]); err != nil {
+			t.Errorf("unsigned request: %v", err)
+		}
+		var vals []string
+		for k, v := range *resp {
+			vals = append(vals, k+"="+v)
+		}
+		w.Write([]byte(strings.Join(vals, "&")))
+	}))
+}
+
+func TestQuery(t *testing.T) {
+	var c *Client
+	var resp map[string]string
+	srv := transServer(t, queryTransPath, &c, &resp)
+	defer srv.Close()
+	c, certID := testClient(t, srv.URL)
+
+	r := DefaultQueryReq()
+	r.OrderID, r.TxnTime = "o1", "20180102150405"
+	resp = signed(t, c, certID, map[string]string{"respCode": "00", "orderId": "o1", "origRespCode": "05"})
+	res, err := c.Query(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OrderID != "o1" || !res.IsPending() {
+		t.Errorf("res = %+v", res)
+	}
+
+	resp["origRespCode"] = "00"
+	if _, err := c.Query(r); err == nil {
+		t.Error("tampered response accepted")
+	}
+
+	resp = signed(t, c, certID, map[string]string{"respCode": "34", "respMsg": "查无此交易"})
+	_, err = c.Query(r)
+	if e, ok := err.(*Error); !ok || e.RespCode != "34" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestIsPending(t *testing.T) {
+	for code, want := range map[string]bool{"00": false, "03": true, "04": true, "05": true, "01": false, "": false} {
+		if got := (&QueryResp{OrigRespCode: code}).IsPending(); got != want {
+			t.Errorf("IsPending of %q = %v", code, got)
+		}
+	}
+}
+
+func TestBackTrans(t *testing.T) {
+	var c *Client
+	var resp map[string]string
+	srv := transServer(t, backTransReqPath, &c, &resp)
+	defer srv.Close()
+	c, certID := testClient(t, srv.URL)
+
+	resp = signed(t, c, certID, map[string]string{"respCode": "00", "queryId": "q1"})
+	tests := []struct {
+		name string
+		f    func(*BackTransReq) (*BackTransResp, error)
+		ok   *BackTransReq
+		bad  *BackTransReq
+	}{
+		{"cancel", c.Cancel, DefaultCancelReq(), DefaultRefundReq()},
+		{"refund", c.Refund, DefaultRefundReq(), DefaultCancelReq()},
+	}
+	for _, tt := range tests {
+		tt.ok.OrigQryID, tt.ok.OrderID, tt.ok.TxnAmt = "q0", "o2", "100"
+		res, err := tt.f(tt.ok)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if res.QueryID != "q1" {
+			t.Errorf("%s: res = %+v", tt.name, res)
+		}
+		if _, err := tt.f(tt.bad); err == nil {
+			t.Errorf("%s: wrong txnType sent", tt.name)
+		}
+	}
+
+	resp = signed(t, c, certID, map[string]string{"respCode": "12", "respMsg": "重复交易"})
+	_, err := c.Refund(DefaultRefundReq())
+	if e, ok := err.(*Error); !ok || e.RespCode != "12" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func notify(m map[string]string) *http.Request {
+	form := url.Values{}
+	for k, v := range m {
+		form.Set(k, v)
+	}
+	req := httptest.NewRequest("POST", "/notify", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestParseNotify(t *testing.T) {
+	c, certID := testClient(t, "")
+
+	m := signed(t, c, certID, map[string]string{"respCode": "00", "txnType": TxnRefund, "queryId": "q1", "settleAmt": "100", "reqReserved": "a=1&b=2"})
+	v := new(BackTransNotifyReq)
+	if err := c.ParseNotify(notify(m), v); err != nil {
+		t.Fatal(err)
+	}
+	if v.QueryID != "q1" || v.SettleAmt != "100" || v.ReqReserved != "a=1&b=2" {
+		t.Errorf("v = %+v", v)
+	}
+
+	m["settleAmt"] = "1"
+	if err := c.ParseNotify(notify(m), new(BackTransNotifyReq)); err == nil {
+		t.Error("tampered notify accepted")
+	}
+	delete(m, "signature")
+	if err := c.ParseNotify(notify(m), new(BackTransNotifyReq)); err == nil {
+		t.Error("unsigned notify accepted")
+	}
+
+	m = signed(t, c, certID, map[string]string{"respCode": "A6", "respMsg": "部分成功"})
+	err := c.ParseNotify(notify(m), new(NotifyReq))
+	if e, ok := err.(*Error); !ok || e.RespCode != "A6" {
+		t.Errorf("got %v", err)
+	}
+}
@@ -5,24 +5,22 @@ import (
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha1"
 	"crypto/x509"
 	"encoding/base64"
-	"encoding/json"
 	"encoding/pem"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/url"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/douglarek/apikit"
 	"github.com/fatih/structs"
 )
 
-const appTransReqURL = "https://gateway.95516.com/gateway/api/appTransReq.do"
+// versions, the signature of 5.0.0 is SHA1 and the one of 5.1.0 is SHA-256.
+const (
+	Version500 = "5.0.0"
+	Version510 = "5.1.0"
+)
 
 // OrderReq ...
 type OrderReq struct {
@@ -51,15 +49,15 @@ type OrderReq struct {
 // DefaultOrderReq ...
 func DefaultOrderReq() *OrderReq {
 	return &OrderReq{
-		Version:      "5.0.0",
+		Version:      Version500,
 		Encoding:     "UTF-8",
-		TxnType:      "01",
+		TxnType:      TxnConsume,
 		TxnSubType:   "01",
 		BizType:      "000201",
 		SignMethod:   "01",
 		ChannelType:  "08",
 		AccessType:   "0",
-		TxnTime:      time.Now().UTC().Add(8 * time.Hour).Format("20060102150405"),
+		TxnTime:      txnTime(),
 		AccType:      "01",
 		CurrencyCode: "156",
 		ReqReserved:  "{}",
@@ -67,8 +65,28 @@ func DefaultOrderReq() *OrderReq {
 
 }
 
+// txnTime returns the current time in Beijing.
+func txnTime() string {
+	return time.Now().UTC().Add(8 * time.Hour).Format("20060102150405")
+}
+
+// values returns the non-empty params of s, which is a struct or a
+// map[string]string.
+func values(s interface{}) map[string]string {
+	if m0, ok := s.(map[string]string); ok {
+		m := make(map[string]string, len(m0))
+		for k, v := range m0 {
+			if v != "" {
+				m[k] = v
+			}
+		}
+		return m
+	}
+	return apikit.Params(structs.Map(s))
+}
+
 func params(s interface{}) []byte {
-	m := apikit.Params(structs.Map(s))
+	m := values(s)
 	delete(m, "signature")
 	keys := make([]string, 0, len(m))
 	for k := range m {
@@ -97,12 +115,35 @@ func Sign(s interface{}, secretKey []byte) string {
 		panic(err)
 	}
 
-	hashed := sha1.Sum([]byte(fmt.Sprintf("%x", sha1.Sum(params(s)))))
-	sign, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA1, hashed[:])
+	sig, err := sign(k, s)
 	if err != nil {
 		panic(err)
 	}
-	return base64.StdEncoding.EncodeToString(sign)
+	return sig
+}
+
+// digest hashes the hex digest of the params of s, with SHA-256 for
+// version 5.1.0 and SHA1 for 5.0.0.
+func digest(s interface{}) (crypto.Hash, []byte) {
+	hash := crypto.SHA1
+	if values(s)["version"] == Version510 {
+		hash = crypto.SHA256
+	}
+	h := hash.New()
+	h.Write(params(s))
+	hex := fmt.Sprintf("%x", h.Sum(nil))
+	h = hash.New()
+	h.Write([]byte(hex))
+	return hash, h.Sum(nil)
+}
+
+func sign(k *rsa.PrivateKey, s interface{}) (string, error) {
+	hash, hashed := digest(s)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, k, hash, hashed)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
 }
 
 // OrderResp ...
@@ -111,34 +152,30 @@ type OrderResp struct {
 	RespCode string `structs:"respCode" json:"respCode"`
 	RespMsg  string `structs:"respMsg" json:"respMsg"`
 	Tn       string `structs:"tn" json:"tn"`
+	// SignPubKeyCert is the signing certificate of 5.1.0 responses.
+	SignPubKeyCert string `structs:"signPubKeyCert" json:"signPubKeyCert"`
 }
 
-// AppConsume ...
-func AppConsume(r *OrderReq) (oresp *OrderResp) {
-	u := url.Values{}
-	for k, v := range apikit.Params(structs.Map(r)) {
-		u.Set(k, v)
-	}
-	resp, err := http.PostForm(appTransReqURL, u)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return
+// AppConsume requests a tn of the production gateway with r signed by the
+// caller, and verifies the 5.0.0 response with verifyCert, i.e.
+// acp_prod_verify_sign.cer.
+//
+// Deprecated: use Client.AppConsume, which signs r and verifies 5.1.0
+// responses too.
+func AppConsume(r *OrderReq, verifyCert []byte) (*OrderResp, error) {
+	c := &Client{
+		client:  apikit.NewClient(nil),
+		gateway: GatewayProd,
+		certs:   map[string]*rsa.PublicKey{},
 	}
-	m := map[string]string{}
-	for _, v := range strings.Split(string(body), "&") {
-		val := strings.SplitN(v, "=", 2)
-		m[val[0]] = val[1]
+	if err := c.AddVerifyCert(verifyCert); err != nil {
+		return nil, err
 	}
-	if m["respCode"] != "00" {
-		return
+	res := new(OrderResp)
+	if err := c.do(appTransReqPath, r, res); err != nil {
+		return nil, err
 	}
-	b, _ := json.Marshal(m)
-	json.Unmarshal(b, &oresp)
-	return oresp
+	return res, nil
 }
 
 // Verify ...
@@ -151,9 +188,13 @@ func Verify(s interface{}, publicKey, sign []byte) error {
 	if err != nil {
 		panic(err)
 	}
-	sign, _ = base64.StdEncoding.DecodeString(string(sign))
-	hashed := sha1.Sum([]byte(fmt.Sprintf("%x", sha1.Sum(params(s)))))
-	return rsa.VerifyPKCS1v15(c.PublicKey.(*rsa.PublicKey), crypto.SHA1, hashed[:], sign)
+	return verify(c.PublicKey.(*rsa.PublicKey), s, string(sign))
+}
+
+func verify(pub *rsa.PublicKey, s interface{}, sign string) error {
+	sig, _ := base64.StdEncoding.DecodeString(sign)
+	hash, hashed := digest(s)
+	return rsa.VerifyPKCS1v15(pub, hash, hashed, sig)
 }
 
 // NotifyReq ...
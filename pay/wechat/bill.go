@@ -0,0 +1,416 @@
+package wechat
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/douglarek/apikit/pay/internal/bill"
+)
+
+// bill types
+const (
+	BillAll            = "ALL"
+	BillSuccess        = "SUCCESS"
+	BillRefund         = "REFUND"
+	BillRechargeRefund = "RECHARGE_REFUND"
+)
+
+// fund flow account types
+const (
+	AccountBasic     = "Basic"
+	AccountOperation = "Operation"
+	AccountFees      = "Fees"
+)
+
+// TarGZIP makes the bill downloaded gzipped.
+const TarGZIP = "GZIP"
+
+// BillReq ...
+type BillReq struct {
+	Req
+	BillDate string `xml:"bill_date" structs:"bill_date"`
+	BillType string `xml:"bill_type" structs:"bill_type"`
+	TarType  string `xml:"tar_type" structs:"tar_type"`
+}
+
+// FundFlowReq must be signed with HMAC-SHA256.
+type FundFlowReq struct {
+	Req
+	SignType    string `xml:"sign_type" structs:"sign_type"`
+	BillDate    string `xml:"bill_date" structs:"bill_date"`
+	AccountType string `xml:"account_type" structs:"account_type"`
+	TarType     string `xml:"tar_type" structs:"tar_type"`
+}
+
+// DownloadBill writes the trade bill to dst, which can be parsed by
+// NewBillReader. An error response is never written to dst.
+func (w *Wechat) DownloadBill(r *BillReq, dst io.Writer) error {
+	return w.download(billURL, r, dst)
+}
+
+// DownloadFundFlow writes the fund flow bill to dst, which can be parsed by
+// NewFundFlowReader. The Wechat must be created with a client returned by
+// TLSClient.
+func (w *Wechat) DownloadFundFlow(r *FundFlowReq, dst io.Writer) error {
+	return w.download(fundFlowURL, r, dst)
+}
+
+// headWriter holds the head of the stream back until it tells whether the
+// response is an <xml> error, which is never written to w.
+type headWriter struct {
+	w    io.Writer
+	head []byte
+	pass bool
+}
+
+var xmlHead = []byte("<xml>")
+
+func (h *headWriter) Write(p []byte) (int, error) {
+	if h.pass {
+		return h.w.Write(p)
+	}
+	h.head = append(h.head, p...)
+	if h.isXML() {
+		if len(h.head) > 64*1024 {
+			return 0, errors.New("wechat: download error response too large")
+		}
+		return len(p), nil
+	}
+	if len(bytes.TrimSpace(h.head)) < len(xmlHead) {
+		return len(p), nil
+	}
+	return len(p), h.flush()
+}
+
+func (h *headWriter) isXML() bool {
+	return bytes.HasPrefix(bytes.TrimSpace(h.head), xmlHead)
+}
+
+// flush writes the held head and passes the rest of the stream through.
+func (h *headWriter) flush() error {
+	h.pass = true
+	_, err := h.w.Write(h.head)
+	h.head = nil
+	return err
+}
+
+func (w *Wechat) download(u string, r interface{}, dst io.Writer) error {
+	req, err := w.client.NewRequest("POST", w.url(u), r)
+	if err != nil {
+		return err
+	}
+	hw := &headWriter{w: dst}
+	if _, err := w.client.Do(req, hw); err != nil {
+		return err
+	}
+	if !hw.isXML() {
+		if hw.pass {
+			return nil
+		}
+		return hw.flush()
+	}
+	var res Resp
+	if err := xml.Unmarshal(hw.head, &res); err != nil {
+		return err
+	}
+	msg := res.ReturnMsg
+	if res.ErrCodeDes != "" {
+		msg = res.ErrCodeDes
+	}
+	return fmt.Errorf("wechat: download failed: %s %s", res.ErrCode, msg)
+}
+
+// billScanner splits a bill into records keyed by the header, rows are
+// prefixed with "`" while the summary trailer and its header are not.
+type billScanner struct {
+	s       *bufio.Scanner
+	header  []string
+	summary map[string]string
+}
+
+func newBillScanner(r io.Reader) (*billScanner, error) {
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(2); err == nil && b[0] == 0x1f && b[1] == 0x8b {
+		if r, err = gzip.NewReader(br); err != nil {
+			return nil, err
+		}
+	} else {
+		r = br
+	}
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	line := strings.TrimPrefix(strings.TrimSpace(s.Text()), "\ufeff")
+	if strings.HasPrefix(line, "<xml>") {
+		return nil, errors.New("wechat: not a bill: " + line)
+	}
+	return &billScanner{s: s, header: strings.Split(line, ",")}, nil
+}
+
+func splitRecord(line string) []string {
+	return strings.Split(strings.TrimPrefix(line, "`"), ",`")
+}
+
+func zipRecord(keys, vals []string) map[string]string {
+	m := make(map[string]string, len(keys))
+	for i, k := range keys {
+		if i < len(vals) {
+			m[strings.TrimSpace(k)] = strings.TrimSpace(vals[i])
+		}
+	}
+	return m
+}
+
+// next returns the next row, or io.EOF once the summary has been read.
+func (b *billScanner) next() (map[string]string, error) {
+	for b.s.Scan() {
+		line := strings.TrimSpace(b.s.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "`") {
+			return zipRecord(b.header, splitRecord(line)), nil
+		}
+		keys := strings.Split(line, ",")
+		if !b.s.Scan() {
+			break
+		}
+		b.summary = zipRecord(keys, splitRecord(strings.TrimSpace(b.s.Text())))
+		return nil, io.EOF
+	}
+	if err := b.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// BillRow is a row of the trade bill, amounts are in fen. Fee has up to 5
+// decimals in the bill and is rounded, FeeYuan keeps it exact so that the
+// fees of the rows add up to the one of the summary.
+type BillRow struct {
+	TradeTime          string
+	AppID              string
+	MchID              string
+	SubMchID           string
+	DeviceInfo         string
+	TransactionID      string
+	OutTradeNo         string
+	OpenID             string
+	TradeType          string
+	TradeState         string
+	BankType           string
+	FeeType            string
+	SettlementTotalFee int64
+	CouponFee          int64
+	RefundID           string
+	OutRefundNo        string
+	RefundFee          int64
+	CouponRefundFee    int64
+	RefundType         string
+	RefundStatus       string
+	Body               string
+	Attach             string
+	Fee                int64
+	FeeYuan            string
+	Rate               string
+	TotalFee           int64
+	ApplyRefundFee     int64
+	RateRemark         string
+	// Fields holds all columns keyed by their original names.
+	Fields map[string]string
+}
+
+// BillSummary is the totals trailer of the trade bill, amounts are in fen
+// and FeeYuan is the exact Fee.
+type BillSummary struct {
+	Count              int
+	SettlementTotalFee int64
+	RefundFee          int64
+	CouponRefundFee    int64
+	Fee                int64
+	FeeYuan            string
+	TotalFee           int64
+	ApplyRefundFee     int64
+	Fields             map[string]string
+}
+
+// BillReader reads a trade bill row by row.
+type BillReader struct {
+	s       *billScanner
+	summary *BillSummary
+}
+
+// NewBillReader returns a reader of the bill written by DownloadBill, it
+// accepts both plain and gzipped bills.
+func NewBillReader(r io.Reader) (*BillReader, error) {
+	s, err := newBillScanner(r)
+	if err != nil {
+		return nil, err
+	}
+	return &BillReader{s: s}, nil
+}
+
+// Read returns the next row, or io.EOF at the end of the rows.
+func (b *BillReader) Read() (*BillRow, error) {
+	m, err := b.s.next()
+	if err == io.EOF && b.s.summary != nil && b.summary == nil {
+		r := &bill.Record{M: b.s.summary}
+		b.summary = &BillSummary{
+			Count:              r.Int("总交易单数"),
+			SettlementTotalFee: r.Fen("应结订单总金额", "总交易额"),
+			RefundFee:          r.Fen("退款总金额", "总退款金额"),
+			CouponRefundFee:    r.Fen("充值券退款总金额", "总代金券或立减优惠退款金额", "总企业红包退款金额"),
+			Fee:                r.Fen("手续费总金额"),
+			FeeYuan:            r.Str("手续费总金额"),
+			TotalFee:           r.Fen("订单总金额"),
+			ApplyRefundFee:     r.Fen("申请退款总金额"),
+			Fields:             r.M,
+		}
+		if r.Err != nil {
+			return nil, r.Err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &bill.Record{M: m}
+	row := &BillRow{
+		TradeTime:          r.Str("交易时间"),
+		AppID:              r.Str("公众账号ID"),
+		MchID:              r.Str("商户号"),
+		SubMchID:           r.Str("特约商户号", "子商户号"),
+		DeviceInfo:         r.Str("设备号"),
+		TransactionID:      r.Str("微信订单号"),
+		OutTradeNo:         r.Str("商户订单号"),
+		OpenID:             r.Str("用户标识"),
+		TradeType:          r.Str("交易类型"),
+		TradeState:         r.Str("交易状态"),
+		BankType:           r.Str("付款银行"),
+		FeeType:            r.Str("货币种类"),
+		SettlementTotalFee: r.Fen("应结订单金额", "总金额"),
+		CouponFee:          r.Fen("代金券金额", "代金券或立减优惠金额", "企业红包金额"),
+		RefundID:           r.Str("微信退款单号"),
+		OutRefundNo:        r.Str("商户退款单号"),
+		RefundFee:          r.Fen("退款金额"),
+		CouponRefundFee:    r.Fen("充值券退款金额", "代金券或立减优惠退款金额", "企业红包退款金额"),
+		RefundType:         r.Str("退款类型"),
+		RefundStatus:       r.Str("退款状态"),
+		Body:               r.Str("商品名称"),
+		Attach:             r.Str("商户数据包"),
+		Fee:                r.Fen("手续费"),
+		FeeYuan:            r.Str("手续费"),
+		Rate:               r.Str("费率"),
+		TotalFee:           r.Fen("订单金额"),
+		ApplyRefundFee:     r.Fen("申请退款金额"),
+		RateRemark:         r.Str("费率备注"),
+		Fields:             m,
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return row, nil
+}
+
+// Summary returns the totals trailer, it is nil until Read returns io.EOF.
+func (b *BillReader) Summary() *BillSummary {
+	return b.summary
+}
+
+// FundFlowRow is a row of the fund flow bill, amounts are in fen.
+type FundFlowRow struct {
+	Time          string
+	TransactionID string
+	FlowID        string
+	BizName       string
+	BizType       string
+	FlowType      string
+	Amount        int64
+	Balance       int64
+	Applicant     string
+	Remark        string
+	VoucherNo     string
+	Fields        map[string]string
+}
+
+// FundFlowSummary is the totals trailer of the fund flow bill.
+type FundFlowSummary struct {
+	Count         int
+	IncomeCount   int
+	IncomeAmount  int64
+	ExpenseCount  int
+	ExpenseAmount int64
+	Fields        map[string]string
+}
+
+// FundFlowReader reads a fund flow bill row by row.
+type FundFlowReader struct {
+	s       *billScanner
+	summary *FundFlowSummary
+}
+
+// NewFundFlowReader returns a reader of the bill written by
+// DownloadFundFlow, it accepts both plain and gzipped bills.
+func NewFundFlowReader(r io.Reader) (*FundFlowReader, error) {
+	s, err := newBillScanner(r)
+	if err != nil {
+		return nil, err
+	}
+	return &FundFlowReader{s: s}, nil
+}
+
+// Read returns the next row, or io.EOF at the end of the rows.
+func (f *FundFlowReader) Read() (*FundFlowRow, error) {
+	m, err := f.s.next()
+	if err == io.EOF && f.s.summary != nil && f.summary == nil {
+		r := &bill.Record{M: f.s.summary}
+		f.summary = &FundFlowSummary{
+			Count:         r.Int("资金流水总笔数"),
+			IncomeCount:   r.Int("收入笔数"),
+			IncomeAmount:  r.Fen("收入金额"),
+			ExpenseCount:  r.Int("支出笔数"),
+			ExpenseAmount: r.Fen("支出金额"),
+			Fields:        r.M,
+		}
+		if r.Err != nil {
+			return nil, r.Err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &bill.Record{M: m}
+	row := &FundFlowRow{
+		Time:          r.Str("记账时间"),
+		TransactionID: r.Str("微信支付业务单号"),
+		FlowID:        r.Str("资金流水单号"),
+		BizName:       r.Str("业务名称"),
+		BizType:       r.Str("业务类型"),
+		FlowType:      r.Str("收支类型"),
+		Amount:        r.Fen("收支金额（元）", "收支金额(元)"),
+		Balance:       r.Fen("账户结余（元）", "账户结余(元)"),
+		Applicant:     r.Str("资金变更提交申请人"),
+		Remark:        r.Str("备注"),
+		VoucherNo:     r.Str("业务凭证号"),
+		Fields:        m,
+	}
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return row, nil
+}
+
+// Summary returns the totals trailer, it is nil until Read returns io.EOF.
+func (f *FundFlowReader) Summary() *FundFlowSummary {
+	return f.summary
+}
@@ -0,0 +1,142 @@
+package wechat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const tradeBill = "\ufeff交易时间,公众账号ID,商户号,特约商户号,设备号,微信订单号,商户订单号,用户标识,交易类型,交易状态,付款银行,货币种类,应结订单金额,代金券金额,微信退款单号,商户退款单号,退款金额,充值券退款金额,退款类型,退款状态,商品名称,商户数据包,手续费,费率,订单金额,申请退款金额,费率备注\r\n" +
+	"`2018-01-02 10:00:00,`wx1,`100,`0,`,`4200,`o1,`oU,`JSAPI,`SUCCESS,`CMB_CREDIT,`CNY,`1.00,`0.00,`0,`0,`0.00,`0.00,`,`,`商品,`,`0.01000,`0.60%,`1.00,`0.00,`\r\n" +
+	"`2018-01-02 11:00:00,`wx1,`100,`0,`,`4201,`o2,`oU,`JSAPI,`REFUND,`CMB_CREDIT,`CNY,`0.00,`0.00,`5000,`r1,`0.5,`0.00,`ORIGINAL,`SUCCESS,`商品,`,`-0.00300,`0.60%,`0.00,`0.50,`\r\n" +
+	"总交易单数,应结订单总金额,退款总金额,充值券退款总金额,手续费总金额,订单总金额,申请退款总金额\r\n" +
+	"`2,`1.00,`0.50,`0.00,`0.00700,`1.00,`0.50\r\n"
+
+func gzipped(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	if _, err := z.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBillReader(t *testing.T) {
+	for name, b := range map[string][]byte{
+		"plain": []byte(tradeBill),
+		"gzip":  gzipped(t, tradeBill),
+	} {
+		r, err := NewBillReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var rows []*BillRow
+		for {
+			row, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) != 2 {
+			t.Fatalf("%s: got %d rows", name, len(rows))
+		}
+		if r := rows[0]; r.TradeTime != "2018-01-02 10:00:00" || r.OutTradeNo != "o1" || r.SettlementTotalFee != 100 || r.Fee != 1 || r.Body != "商品" {
+			t.Errorf("%s: row 0 = %+v", name, r)
+		}
+		if r := rows[1]; r.RefundID != "5000" || r.RefundFee != 50 || r.ApplyRefundFee != 50 {
+			t.Errorf("%s: row 1 = %+v", name, r)
+		}
+		want := BillSummary{Count: 2, SettlementTotalFee: 100, RefundFee: 50, Fee: 1, FeeYuan: "0.00700", TotalFee: 100, ApplyRefundFee: 50}
+		got := *r.Summary()
+		got.Fields = nil
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: summary = %+v, want %+v", name, got, want)
+		}
+
+		// the exact fees add up to the summary, the rounded ones need not.
+		sum, total := new(big.Rat), new(big.Rat)
+		for _, row := range rows {
+			fee, ok := new(big.Rat).SetString(row.FeeYuan)
+			if !ok {
+				t.Fatalf("%s: FeeYuan = %q", name, row.FeeYuan)
+			}
+			sum.Add(sum, fee)
+		}
+		if _, ok := total.SetString(got.FeeYuan); !ok || sum.Cmp(total) != 0 {
+			t.Errorf("%s: fees add up to %s, want %s", name, sum.FloatString(5), got.FeeYuan)
+		}
+	}
+}
+
+func TestFundFlowReader(t *testing.T) {
+	bill := "记账时间,微信支付业务单号,资金流水单号,业务名称,业务类型,收支类型,收支金额（元）,账户结余（元）,资金变更提交申请人,备注,业务凭证号\r\n" +
+		"`2018-01-02 10:00:00,`4200,`f1,`交易,`交易,`收入,`1.00,`10.00,`system,`,`v1\r\n" +
+		"资金流水总笔数,收入笔数,收入金额,支出笔数,支出金额\r\n" +
+		"`1,`1,`1.00,`0,`0.00\r\n"
+	r, err := NewFundFlowReader(strings.NewReader(bill))
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.FlowID != "f1" || row.Amount != 100 || row.Balance != 1000 || row.VoucherNo != "v1" {
+		t.Errorf("row = %+v", row)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+	if s := r.Summary(); s == nil || s.Count != 1 || s.IncomeAmount != 100 {
+		t.Errorf("summary = %+v", s)
+	}
+}
+
+func TestBillReaderError(t *testing.T) {
+	if _, err := NewBillReader(strings.NewReader("<xml><return_code><![CDATA[FAIL]]></return_code></xml>")); err == nil {
+		t.Error("want error for an <xml> response")
+	}
+	if _, err := NewBillReader(strings.NewReader("")); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestHeadWriter(t *testing.T) {
+	tests := []struct {
+		chunks []string
+		xml    bool
+		out    string
+	}{
+		{[]string{"  <x", "ml><return_code>FAIL</return_code></xml>"}, true, ""},
+		{[]string{"交易", "时间,公众账号ID\r\n", "`1"}, false, "交易时间,公众账号ID\r\n`1"},
+		{[]string{"ab"}, false, "ab"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		h := &headWriter{w: &buf}
+		for _, c := range tt.chunks {
+			if _, err := h.Write([]byte(c)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !h.isXML() && !h.pass {
+			if err := h.flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if h.isXML() != tt.xml || buf.String() != tt.out {
+			t.Errorf("%q: xml %v, wrote %q", tt.chunks, h.isXML(), buf.String())
+		}
+	}
+}
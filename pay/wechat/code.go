@@ -0,0 +1,112 @@
+package wechat
+
+// Code is the value of return_code and result_code.
+type Code string
+
+// codes
+const (
+	CodeSuccess Code = "SUCCESS"
+	CodeFail    Code = "FAIL"
+)
+
+// TradeState is the value of trade_state.
+type TradeState string
+
+// trade states
+const (
+	TradeSuccess    TradeState = "SUCCESS"
+	TradeRefund     TradeState = "REFUND"
+	TradeNotPay     TradeState = "NOTPAY"
+	TradeClosed     TradeState = "CLOSED"
+	TradeRevoked    TradeState = "REVOKED"
+	TradeUserPaying TradeState = "USERPAYING"
+	TradePayError   TradeState = "PAYERROR"
+)
+
+// IsFinal reports whether the trade won't change any more without a new
+// operation such as refund.
+func (s TradeState) IsFinal() bool {
+	switch s {
+	case TradeSuccess, TradeRefund, TradeClosed, TradeRevoked, TradePayError:
+		return true
+	}
+	return false
+}
+
+// TradeType is the value of trade_type.
+type TradeType string
+
+// trade types
+const (
+	TradeJSAPI    TradeType = "JSAPI"
+	TradeNative   TradeType = "NATIVE"
+	TradeApp      TradeType = "APP"
+	TradeMWeb     TradeType = "MWEB"
+	TradeMicropay TradeType = "MICROPAY"
+)
+
+// ErrCode is the value of err_code.
+type ErrCode string
+
+// documented error codes
+const (
+	ErrSystem              ErrCode = "SYSTEMERROR"
+	ErrParam               ErrCode = "PARAM_ERROR"
+	ErrOrderPaid           ErrCode = "ORDERPAID"
+	ErrNoAuth              ErrCode = "NOAUTH"
+	ErrAuthCodeExpire      ErrCode = "AUTHCODEEXPIRE"
+	ErrNotEnough           ErrCode = "NOTENOUGH"
+	ErrNotSupportCard      ErrCode = "NOTSUPORTCARD"
+	ErrOrderClosed         ErrCode = "ORDERCLOSED"
+	ErrOrderReversed       ErrCode = "ORDERREVERSED"
+	ErrBank                ErrCode = "BANKERROR"
+	ErrUserPaying          ErrCode = "USERPAYING"
+	ErrAuthCode            ErrCode = "AUTH_CODE_ERROR"
+	ErrAuthCodeInvalid     ErrCode = "AUTH_CODE_INVALID"
+	ErrXMLFormat           ErrCode = "XML_FORMAT_ERROR"
+	ErrRequirePOST         ErrCode = "REQUIRE_POST_METHOD"
+	ErrSign                ErrCode = "SIGNERROR"
+	ErrLackParams          ErrCode = "LACK_PARAMS"
+	ErrNotUTF8             ErrCode = "NOT_UTF8"
+	ErrBuyerMismatch       ErrCode = "BUYER_MISMATCH"
+	ErrAppIDNotExist       ErrCode = "APPID_NOT_EXIST"
+	ErrMchIDNotExist       ErrCode = "MCHID_NOT_EXIST"
+	ErrAppIDMchIDNotMatch  ErrCode = "APPID_MCHID_NOT_MATCH"
+	ErrOutTradeNoUsed      ErrCode = "OUT_TRADE_NO_USED"
+	ErrOrderNotExist       ErrCode = "ORDERNOTEXIST"
+	ErrInvalidRequest      ErrCode = "INVALID_REQUEST"
+	ErrTrade               ErrCode = "TRADE_ERROR"
+	ErrFrequencyLimited    ErrCode = "FREQUENCY_LIMITED"
+	ErrInvalidReqTooMuch   ErrCode = "INVALID_REQ_TOO_MUCH"
+	ErrRefundNotExist      ErrCode = "REFUNDNOTEXIST"
+	ErrInvalidTransaction  ErrCode = "INVALID_TRANSACTIONID"
+	ErrUserAccountAbnormal ErrCode = "USER_ACCOUNT_ABNORMAL"
+	ErrPostDataEmpty       ErrCode = "POST_DATA_EMPTY"
+	ErrCert                ErrCode = "CERT_ERROR"
+	ErrBiz                 ErrCode = "ERROR"
+)
+
+// IsRetryable reports whether the same request may succeed when retried
+// later.
+func (c ErrCode) IsRetryable() bool {
+	switch c {
+	case ErrSystem, ErrBank, ErrFrequencyLimited, ErrInvalidReqTooMuch:
+		return true
+	}
+	return false
+}
+
+// IsSuccess reports whether both return_code and result_code are SUCCESS.
+func (r *Resp) IsSuccess() bool {
+	return r.ReturnCode == CodeSuccess && r.ResultCode == CodeSuccess
+}
+
+// IsRetryable reports whether the failed request may be retried.
+func (r *Resp) IsRetryable() bool {
+	return r.ResultCode == CodeFail && r.ErrCode.IsRetryable()
+}
+
+// IsFinal reports whether the queried trade state is final.
+func (r *QueryResp) IsFinal() bool {
+	return r.IsSuccess() && r.TradeState.IsFinal()
+}
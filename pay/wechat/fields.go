@@ -0,0 +1,142 @@
+package wechat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+)
+
+// Fields holds every field of a response keyed by its name, including the
+// indexed ones like coupon_id_0 which can't be mapped by struct tags, so
+// the signature can be verified over the full payload.
+type Fields map[string]string
+
+// Coupon is a coupon used by a payment or refund.
+type Coupon struct {
+	ID   string `json:"couponId"`
+	Type string `json:"couponType"`
+	Fee  int    `json:"couponFee"`
+}
+
+func decodeFields(d *xml.Decoder, start xml.StartElement) (Fields, error) {
+	f := Fields{}
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			var s string
+			if err := d.DecodeElement(&s, &t); err != nil {
+				return nil, err
+			}
+			f[t.Name.Local] = s
+		case xml.EndElement:
+			return f, nil
+		}
+	}
+}
+
+// unmarshal decodes the fixed fields into v by its struct tags.
+func (f Fields) unmarshal(root string, v interface{}) error {
+	var buf bytes.Buffer
+	buf.WriteString("<" + root + ">")
+	for k, val := range f {
+		buf.WriteString("<" + k + ">")
+		xml.EscapeText(&buf, []byte(val))
+		buf.WriteString("</" + k + ">")
+	}
+	buf.WriteString("</" + root + ">")
+	return xml.Unmarshal(buf.Bytes(), v)
+}
+
+func (f Fields) int(k string) int {
+	n, _ := strconv.Atoi(f[k])
+	return n
+}
+
+// coupons collects coupons indexed by suffix_0, suffix_1 ...
+func (f Fields) coupons(id, typ, fee, suffix string) []Coupon {
+	var cs []Coupon
+	for i := 0; ; i++ {
+		n := suffix + "_" + strconv.Itoa(i)
+		if _, ok := f[id+n]; !ok {
+			return cs
+		}
+		cs = append(cs, Coupon{ID: f[id+n], Type: f[typ+n], Fee: f.int(fee + n)})
+	}
+}
+
+// UnmarshalXML ...
+func (r *QueryResp) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	f, err := decodeFields(d, start)
+	if err != nil {
+		return err
+	}
+	type plain QueryResp
+	if err := f.unmarshal(start.Name.Local, (*plain)(r)); err != nil {
+		return err
+	}
+	r.Coupons = f.coupons("coupon_id", "coupon_type", "coupon_fee", "")
+	r.Fields = f
+	return nil
+}
+
+// UnmarshalXML ...
+func (r *RefundResp) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	f, err := decodeFields(d, start)
+	if err != nil {
+		return err
+	}
+	type plain RefundResp
+	if err := f.unmarshal(start.Name.Local, (*plain)(r)); err != nil {
+		return err
+	}
+	r.Coupons = f.coupons("coupon_refund_id", "coupon_type", "coupon_refund_fee", "")
+	r.Fields = f
+	return nil
+}
+
+// UnmarshalXML ...
+func (r *RefundQueryResp) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	f, err := decodeFields(d, start)
+	if err != nil {
+		return err
+	}
+	type plain RefundQueryResp
+	if err := f.unmarshal(start.Name.Local, (*plain)(r)); err != nil {
+		return err
+	}
+	r.Refunds = nil
+	for i := 0; ; i++ {
+		n := "_" + strconv.Itoa(i)
+		if _, ok := f["out_refund_no"+n]; !ok {
+			break
+		}
+		r.Refunds = append(r.Refunds, RefundItem{
+			OutRefundNo:         f["out_refund_no"+n],
+			RefundID:            f["refund_id"+n],
+			RefundChannel:       f["refund_channel"+n],
+			RefundFee:           f.int("refund_fee" + n),
+			SettlementRefundFee: f.int("settlement_refund_fee" + n),
+			CouponRefundFee:     f.int("coupon_refund_fee" + n),
+			CouponRefundCount:   f.int("coupon_refund_count" + n),
+			RefundStatus:        f["refund_status"+n],
+			RefundAccount:       f["refund_account"+n],
+			RefundRecvAccout:    f["refund_recv_accout"+n],
+			RefundSuccessTime:   f["refund_success_time"+n],
+			Coupons:             f.coupons("coupon_refund_id", "coupon_type", "coupon_refund_fee", n),
+		})
+	}
+	r.Fields = f
+	return nil
+}
+
+// Verify reports whether the sign of fields, e.g. QueryResp.Fields or
+// NotifyReq.Fields, is valid. In sandbox mode it is checked against the
+// sandbox key of secret, and is invalid if the key cannot be fetched.
+func (w *Wechat) Verify(f Fields, secret string) bool {
+	key, err := w.signKey(secret)
+	return err == nil && f["sign"] != "" && signParams(f, key) == f["sign"]
+}
@@ -0,0 +1,88 @@
+package wechat
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestQueryRespCoupons(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []Coupon
+	}{
+		{"<xml><return_code>SUCCESS</return_code><out_trade_no>o1</out_trade_no></xml>", nil},
+		{
+			"<xml><return_code>SUCCESS</return_code><out_trade_no>o1</out_trade_no><coupon_count>2</coupon_count>" +
+				"<coupon_id_0>c0</coupon_id_0><coupon_type_0>CASH</coupon_type_0><coupon_fee_0>10</coupon_fee_0>" +
+				"<coupon_id_1>c1</coupon_id_1><coupon_type_1>NO_CASH</coupon_type_1><coupon_fee_1>20</coupon_fee_1>" +
+				"<coupon_id_3>c3</coupon_id_3></xml>",
+			[]Coupon{{"c0", "CASH", 10}, {"c1", "NO_CASH", 20}},
+		},
+	}
+	for _, tt := range tests {
+		var r QueryResp
+		if err := xml.Unmarshal([]byte(tt.in), &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.ReturnCode != CodeSuccess || r.OutTradeNo != "o1" {
+			t.Errorf("fixed fields = %+v", r)
+		}
+		if !reflect.DeepEqual(r.Coupons, tt.want) {
+			t.Errorf("coupons = %+v, want %+v", r.Coupons, tt.want)
+		}
+		if r.Fields["return_code"] != "SUCCESS" {
+			t.Errorf("fields = %v", r.Fields)
+		}
+	}
+}
+
+func TestRefundQueryRespRefunds(t *testing.T) {
+	in := "<xml><return_code>SUCCESS</return_code><refund_count>2</refund_count>" +
+		"<out_refund_no_0>r0</out_refund_no_0><refund_id_0>50</refund_id_0><refund_fee_0>100</refund_fee_0>" +
+		"<refund_status_0>SUCCESS</refund_status_0><coupon_refund_count_0>2</coupon_refund_count_0>" +
+		"<coupon_refund_id_0_0>c0</coupon_refund_id_0_0><coupon_type_0_0>CASH</coupon_type_0_0><coupon_refund_fee_0_0>5</coupon_refund_fee_0_0>" +
+		"<coupon_refund_id_0_1>c1</coupon_refund_id_0_1><coupon_refund_fee_0_1>6</coupon_refund_fee_0_1>" +
+		"<out_refund_no_1>r1</out_refund_no_1><refund_fee_1>200</refund_fee_1><refund_status_1>PROCESSING</refund_status_1>" +
+		"</xml>"
+	var r RefundQueryResp
+	if err := xml.Unmarshal([]byte(in), &r); err != nil {
+		t.Fatal(err)
+	}
+	want := []RefundItem{
+		{
+			OutRefundNo:       "r0",
+			RefundID:          "50",
+			RefundFee:         100,
+			RefundStatus:      "SUCCESS",
+			CouponRefundCount: 2,
+			Coupons:           []Coupon{{"c0", "CASH", 5}, {"c1", "", 6}},
+		},
+		{OutRefundNo: "r1", RefundFee: 200, RefundStatus: "PROCESSING"},
+	}
+	if !reflect.DeepEqual(r.Refunds, want) {
+		t.Errorf("refunds = %+v, want %+v", r.Refunds, want)
+	}
+	if r.Fields["coupon_refund_id_0_1"] != "c1" {
+		t.Errorf("fields = %v", r.Fields)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	w := New(nil)
+	f := Fields{"return_code": "SUCCESS", "out_trade_no": "o1", "coupon_id_0": "c0", "attach": ""}
+	f["sign"] = signParams(f, "secret")
+	if !w.Verify(f, "secret") {
+		t.Error("valid sign rejected")
+	}
+	if w.Verify(f, "other") {
+		t.Error("sign of another secret accepted")
+	}
+	f["coupon_id_0"] = "c1"
+	if w.Verify(f, "secret") {
+		t.Error("tampered indexed field accepted")
+	}
+	if w.Verify(Fields{"return_code": "SUCCESS"}, "secret") {
+		t.Error("unsigned fields accepted")
+	}
+}
@@ -0,0 +1,174 @@
+package wechat
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/douglarek/apikit/pay/internal/poll"
+)
+
+// ErrReversed is returned by MicropayWait when the payment is not finished
+// before the deadline and the order has been reversed.
+var ErrReversed = errors.New("wechat: micropay timed out, order reversed")
+
+// MicropayReq ...
+type MicropayReq struct {
+	Req
+	DeviceInfo     string `xml:"device_info" structs:"device_info"`
+	Body           string `xml:"body" structs:"body"`
+	Detail         string `xml:"detail" structs:"detail"`
+	Attach         string `xml:"attach" structs:"attach"`
+	OutTradeNo     string `xml:"out_trade_no" structs:"out_trade_no"`
+	TotalFee       int    `xml:"total_fee" structs:"total_fee"`
+	FeeType        string `xml:"fee_type" structs:"fee_type"`
+	SpbillCreateIP string `xml:"spbill_create_ip" structs:"spbill_create_ip"`
+	GoodsTag       string `xml:"goods_tag" structs:"goods_tag"`
+	LimitPay       string `xml:"limit_pay" structs:"limit_pay"`
+	TimeStart      string `xml:"time_start" structs:"time_start"`
+	TimeExpire     string `xml:"time_expire" structs:"time_expire"`
+	AuthCode       string `xml:"auth_code" structs:"auth_code"`
+}
+
+// MicropayResp ...
+type MicropayResp struct {
+	Resp
+	Req
+	DeviceInfo    string    `structs:"device_info" xml:"device_info" json:"deviceInfo"`
+	OpenID        string    `structs:"openid" xml:"openid" json:"openId"`
+	IsSubscribe   string    `structs:"is_subscribe" xml:"is_subscribe" json:"isSubscribe"`
+	TradeType     TradeType `structs:"trade_type" xml:"trade_type" json:"tradeType"`
+	BankType      string    `structs:"bank_type" xml:"bank_type" json:"bankType"`
+	FeeType       string    `structs:"fee_type" xml:"fee_type" json:"feeType"`
+	TotalFee      string    `structs:"total_fee" xml:"total_fee" json:"totalFee"`
+	CashFee       int       `structs:"cash_fee" xml:"cash_fee" json:"cashFee"`
+	CashFeeType   string    `structs:"cash_fee_type" xml:"cash_fee_type" json:"cashFeeType"`
+	CouponFee     int       `structs:"coupon_fee" xml:"coupon_fee" json:"couponFee"`
+	TransactionID string    `structs:"transaction_id" xml:"transaction_id" json:"transactionId"`
+	OutTradeNo    string    `structs:"out_trade_no" xml:"out_trade_no" json:"outTradeNo"`
+	Attach        string    `structs:"attach" xml:"attach" json:"attach"`
+	TimeEnd       string    `structs:"time_end" xml:"time_end" json:"timeEnd"`
+}
+
+// Micropay charges the payment code scanned from the user, a USERPAYING
+// result needs to be polled, see MicropayWait.
+func (w *Wechat) Micropay(r *MicropayReq) (*MicropayResp, error) {
+	res := new(MicropayResp)
+	if err := w.post(micropayURL, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ReverseReq ...
+type ReverseReq struct {
+	Req
+	TransactionID string `xml:"transaction_id" structs:"transaction_id"`
+	OutTradeNo    string `xml:"out_trade_no" structs:"out_trade_no"`
+}
+
+// ReverseResp ...
+type ReverseResp struct {
+	Resp
+	Req
+	Recall string `structs:"recall" xml:"recall" json:"recall"`
+}
+
+// Reverse cancels a micropay order, the Wechat must be created with a
+// client returned by TLSClient.
+func (w *Wechat) Reverse(r *ReverseReq) (*ReverseResp, error) {
+	res := new(ReverseResp)
+	if err := w.post(reverseURL, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// MicropayWait signs and sends r, polls Query with backoff while the user
+// is paying, and reverses the order if it does not succeed before deadline.
+func (w *Wechat) MicropayWait(r *MicropayReq, secret string, deadline time.Time) (*QueryResp, error) {
+	key, err := w.signKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	r.NonceStr = nonceStr()
+	r.Sign = sign(r, key)
+	res, err := w.Micropay(r)
+	if err == nil {
+		// a bad signature or bad params never created the order.
+		if res.ReturnCode != CodeSuccess {
+			return nil, fmt.Errorf("wechat: micropay failed: %s", res.ReturnMsg)
+		}
+		if res.ResultCode == CodeSuccess {
+			return micropayQueryResp(res), nil
+		}
+		if res.ErrCode != ErrUserPaying && !res.ErrCode.IsRetryable() {
+			return nil, fmt.Errorf("wechat: micropay failed: %s %s", res.ErrCode, res.ErrCodeDes)
+		}
+	}
+
+	// the user is paying or the result is unknown, poll until it is final.
+	q := &QueryReq{OutTradeNo: r.OutTradeNo}
+	q.AppID, q.MchID = r.AppID, r.MchID
+	var qr *QueryResp
+	if poll.Until(deadline, func() bool {
+		q.NonceStr = nonceStr()
+		q.Sign = sign(q, key)
+		qr, err = w.Query(q)
+		if err != nil || !qr.IsSuccess() {
+			return false
+		}
+		if qr.TradeState == TradeSuccess {
+			return true
+		}
+		if qr.IsFinal() {
+			err = fmt.Errorf("wechat: micropay failed: %s %s", qr.TradeState, qr.TradeStateDesc)
+			return true
+		}
+		return false
+	}) {
+		return qr, err
+	}
+
+	rv := &ReverseReq{OutTradeNo: r.OutTradeNo}
+	rv.AppID, rv.MchID = r.AppID, r.MchID
+	if poll.Retry(func() bool {
+		rv.NonceStr = nonceStr()
+		rv.Sign = sign(rv, key)
+		rr, e := w.Reverse(rv)
+		if e == nil && rr.IsSuccess() {
+			err = ErrReversed
+			return true
+		}
+		if e == nil && rr.Recall != "Y" && rr.ReturnCode == CodeSuccess {
+			err = fmt.Errorf("wechat: reverse failed: %s %s", rr.ErrCode, rr.ErrCodeDes)
+			return true
+		}
+		return false
+	}) {
+		return nil, err
+	}
+	return nil, errors.New("wechat: micropay timed out, reverse failed")
+}
+
+func micropayQueryResp(r *MicropayResp) *QueryResp {
+	return &QueryResp{
+		Resp:          r.Resp,
+		Req:           r.Req,
+		DeviceInfo:    r.DeviceInfo,
+		OpenID:        r.OpenID,
+		IsSubscribe:   r.IsSubscribe,
+		TradeType:     r.TradeType,
+		TradeState:    TradeSuccess,
+		BankType:      r.BankType,
+		TotalFee:      r.TotalFee,
+		FeeType:       r.FeeType,
+		CashFee:       r.CashFee,
+		CashFeeType:   r.CashFeeType,
+		CouponFee:     r.CouponFee,
+		TransactionID: r.TransactionID,
+		OutTradeNo:    r.OutTradeNo,
+		Attach:        r.Attach,
+		TimeEnd:       r.TimeEnd,
+	}
+}
@@ -0,0 +1,87 @@
+package wechat
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var errNoPrepayID = errors.New("wechat: order response has no prepay_id")
+
+func nonceStr() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func timeStamp() string {
+	return strconv.FormatInt(time.Now().Unix(), 10)
+}
+
+// AppParams returns the signed AppReq of an APP order for the client SDK.
+func (w *Wechat) AppParams(r *OrderResp, secret string) (*AppReq, error) {
+	if r.PrepayID == "" {
+		return nil, errNoPrepayID
+	}
+	key, err := w.signKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	a := &AppReq{
+		AppID:     r.AppID,
+		PartnerID: r.MchID,
+		PrepayID:  r.PrepayID,
+		Package:   "Sign=WXPay",
+		NonceStr:  nonceStr(),
+		TimeStamp: timeStamp(),
+	}
+	a.Sign = sign(a, key)
+	return a, nil
+}
+
+// JSReq packages needed params for JSAPI (WeixinJSBridge getBrandWCPayRequest)
+// and mini program (wx.requestPayment).
+type JSReq struct {
+	AppID     string `structs:"appId" json:"appId"`
+	TimeStamp string `structs:"timeStamp" json:"timeStamp"`
+	NonceStr  string `structs:"nonceStr" json:"nonceStr"`
+	Package   string `structs:"package" json:"package"`
+	SignType  string `structs:"signType" json:"signType"`
+	PaySign   string `structs:"-" json:"paySign"`
+}
+
+// JSParams returns the signed JSReq of a JSAPI order.
+func (w *Wechat) JSParams(r *OrderResp, secret string) (*JSReq, error) {
+	if r.PrepayID == "" {
+		return nil, errNoPrepayID
+	}
+	key, err := w.signKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	j := &JSReq{
+		AppID:     r.AppID,
+		TimeStamp: timeStamp(),
+		NonceStr:  nonceStr(),
+		Package:   "prepay_id=" + r.PrepayID,
+		SignType:  SignMD5,
+	}
+	j.PaySign = sign(j, key)
+	return j, nil
+}
+
+// H5URL returns the mweb_url of an MWEB order which the browser should be
+// redirected to, redirectURL is where WeChat returns after payment and may
+// be empty.
+func H5URL(r *OrderResp, redirectURL string) (string, error) {
+	if r.MWebURL == "" {
+		return "", errors.New("wechat: order response has no mweb_url")
+	}
+	if redirectURL == "" {
+		return r.MWebURL, nil
+	}
+	return r.MWebURL + "&redirect_url=" + url.QueryEscape(redirectURL), nil
+}
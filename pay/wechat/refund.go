@@ -0,0 +1,172 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+)
+
+// RefundReq ...
+type RefundReq struct {
+	Req
+	TransactionID string `xml:"transaction_id" structs:"transaction_id"`
+	OutTradeNo    string `xml:"out_trade_no" structs:"out_trade_no"`
+	OutRefundNo   string `xml:"out_refund_no" structs:"out_refund_no"`
+	TotalFee      int    `xml:"total_fee" structs:"total_fee"`
+	RefundFee     int    `xml:"refund_fee" structs:"refund_fee"`
+	RefundFeeType string `xml:"refund_fee_type" structs:"refund_fee_type"`
+	RefundDesc    string `xml:"refund_desc" structs:"refund_desc"`
+	RefundAccount string `xml:"refund_account" structs:"refund_account"`
+	NotifyURL     string `xml:"notify_url" structs:"notify_url"`
+}
+
+// RefundResp ...
+type RefundResp struct {
+	Resp
+	Req
+	TransactionID       string   `structs:"transaction_id" xml:"transaction_id" json:"transactionId"`
+	OutTradeNo          string   `structs:"out_trade_no" xml:"out_trade_no" json:"outTradeNo"`
+	OutRefundNo         string   `structs:"out_refund_no" xml:"out_refund_no" json:"outRefundNo"`
+	RefundID            string   `structs:"refund_id" xml:"refund_id" json:"refundId"`
+	RefundFee           int      `structs:"refund_fee" xml:"refund_fee" json:"refundFee"`
+	SettlementRefundFee int      `structs:"settlement_refund_fee" xml:"settlement_refund_fee" json:"settlementRefundFee"`
+	TotalFee            int      `structs:"total_fee" xml:"total_fee" json:"totalFee"`
+	SettlementTotalFee  int      `structs:"settlement_total_fee" xml:"settlement_total_fee" json:"settlementTotalFee"`
+	FeeType             string   `structs:"fee_type" xml:"fee_type" json:"feeType"`
+	CashFee             int      `structs:"cash_fee" xml:"cash_fee" json:"cashFee"`
+	CashFeeType         string   `structs:"cash_fee_type" xml:"cash_fee_type" json:"cashFeeType"`
+	CashRefundFee       int      `structs:"cash_refund_fee" xml:"cash_refund_fee" json:"cashRefundFee"`
+	CouponRefundFee     int      `structs:"coupon_refund_fee" xml:"coupon_refund_fee" json:"couponRefundFee"`
+	CouponRefundCount   int      `structs:"coupon_refund_count" xml:"coupon_refund_count" json:"couponRefundCount"`
+	Coupons             []Coupon `structs:"-" xml:"-" json:"coupons"`
+	Fields              Fields   `structs:"-" xml:"-" json:"-"`
+}
+
+// Refund requests a refund, the Wechat must be created with a client
+// returned by TLSClient.
+func (w *Wechat) Refund(r *RefundReq) (*RefundResp, error) {
+	res := new(RefundResp)
+	if err := w.post(refundURL, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RefundQueryReq ...
+type RefundQueryReq struct {
+	Req
+	TransactionID string `xml:"transaction_id" structs:"transaction_id"`
+	OutTradeNo    string `xml:"out_trade_no" structs:"out_trade_no"`
+	OutRefundNo   string `xml:"out_refund_no" structs:"out_refund_no"`
+	RefundID      string `xml:"refund_id" structs:"refund_id"`
+	Offset        int    `xml:"offset" structs:"offset"`
+}
+
+// RefundQueryResp ...
+type RefundQueryResp struct {
+	Resp
+	Req
+	TransactionID      string       `structs:"transaction_id" xml:"transaction_id" json:"transactionId"`
+	OutTradeNo         string       `structs:"out_trade_no" xml:"out_trade_no" json:"outTradeNo"`
+	TotalFee           int          `structs:"total_fee" xml:"total_fee" json:"totalFee"`
+	SettlementTotalFee int          `structs:"settlement_total_fee" xml:"settlement_total_fee" json:"settlementTotalFee"`
+	FeeType            string       `structs:"fee_type" xml:"fee_type" json:"feeType"`
+	CashFee            int          `structs:"cash_fee" xml:"cash_fee" json:"cashFee"`
+	RefundCount        int          `structs:"refund_count" xml:"refund_count" json:"refundCount"`
+	TotalRefundCount   int          `structs:"total_refund_count" xml:"total_refund_count" json:"totalRefundCount"`
+	Refunds            []RefundItem `structs:"-" xml:"-" json:"refunds"`
+	Fields             Fields       `structs:"-" xml:"-" json:"-"`
+}
+
+// RefundItem is a refund of RefundQueryResp.
+type RefundItem struct {
+	OutRefundNo         string   `json:"outRefundNo"`
+	RefundID            string   `json:"refundId"`
+	RefundChannel       string   `json:"refundChannel"`
+	RefundFee           int      `json:"refundFee"`
+	SettlementRefundFee int      `json:"settlementRefundFee"`
+	CouponRefundFee     int      `json:"couponRefundFee"`
+	CouponRefundCount   int      `json:"couponRefundCount"`
+	RefundStatus        string   `json:"refundStatus"`
+	RefundAccount       string   `json:"refundAccount"`
+	RefundRecvAccout    string   `json:"refundRecvAccout"`
+	RefundSuccessTime   string   `json:"refundSuccessTime"`
+	Coupons             []Coupon `json:"coupons"`
+}
+
+// RefundQuery ...
+func (w *Wechat) RefundQuery(r *RefundQueryReq) (*RefundQueryResp, error) {
+	res := new(RefundQueryResp)
+	if err := w.post(refundQueryURL, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RefundNotifyReq is the refund result notification, its ReqInfo is
+// encrypted and can be decrypted by Wechat.DecryptRefundNotify.
+type RefundNotifyReq struct {
+	XMLName    xml.Name `xml:"xml" json:"-"`
+	ReturnCode Code     `xml:"return_code" json:"returnCode"`
+	ReturnMsg  string   `xml:"return_msg" json:"returnMsg"`
+	AppID      string   `xml:"appid" json:"appId"`
+	MchID      string   `xml:"mch_id" json:"mchId"`
+	NonceStr   string   `xml:"nonce_str" json:"nonceStr"`
+	ReqInfo    string   `xml:"req_info" json:"reqInfo"`
+}
+
+// RefundNotifyInfo is the decrypted req_info of RefundNotifyReq.
+type RefundNotifyInfo struct {
+	XMLName             xml.Name `xml:"root" json:"-"`
+	TransactionID       string   `xml:"transaction_id" json:"transactionId"`
+	OutTradeNo          string   `xml:"out_trade_no" json:"outTradeNo"`
+	RefundID            string   `xml:"refund_id" json:"refundId"`
+	OutRefundNo         string   `xml:"out_refund_no" json:"outRefundNo"`
+	TotalFee            int      `xml:"total_fee" json:"totalFee"`
+	SettlementTotalFee  int      `xml:"settlement_total_fee" json:"settlementTotalFee"`
+	RefundFee           int      `xml:"refund_fee" json:"refundFee"`
+	SettlementRefundFee int      `xml:"settlement_refund_fee" json:"settlementRefundFee"`
+	RefundStatus        string   `xml:"refund_status" json:"refundStatus"`
+	SuccessTime         string   `xml:"success_time" json:"successTime"`
+	RefundRecvAccout    string   `xml:"refund_recv_accout" json:"refundRecvAccout"`
+	RefundAccount       string   `xml:"refund_account" json:"refundAccount"`
+	RefundRequestSource string   `xml:"refund_request_source" json:"refundRequestSource"`
+}
+
+// DecryptRefundNotify decrypts req_info of r, which is AES-256-ECB
+// encrypted with the lowercase hex MD5 of the API secret, or of its sandbox
+// key in sandbox mode.
+func (w *Wechat) DecryptRefundNotify(r *RefundNotifyReq, secret string) (*RefundNotifyInfo, error) {
+	key, err := w.signKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(r.ReqInfo)
+	if err != nil {
+		return nil, err
+	}
+	sum := md5.Sum([]byte(key))
+	block, err := aes.NewCipher([]byte(hex.EncodeToString(sum[:])))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 || len(b)%block.BlockSize() != 0 {
+		return nil, errors.New("wechat: malformed req_info")
+	}
+	for i := 0; i < len(b); i += block.BlockSize() {
+		block.Decrypt(b[i:], b[i:])
+	}
+	n := int(b[len(b)-1])
+	if n == 0 || n > block.BlockSize() || !bytes.Equal(b[len(b)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, errors.New("wechat: bad req_info padding")
+	}
+	info := new(RefundNotifyInfo)
+	if err := xml.Unmarshal(b[:len(b)-n], info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
@@ -0,0 +1,68 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+// reqInfo encrypts b as req_info with AES-256-ECB, keyed by the hex MD5 of
+// secret, after padding it with pad.
+func reqInfo(t *testing.T, b []byte, secret string, pad func([]byte) []byte) string {
+	sum := md5.Sum([]byte(secret))
+	block, err := aes.NewCipher([]byte(hex.EncodeToString(sum[:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = pad(append([]byte(nil), b...))
+	for i := 0; i+aes.BlockSize <= len(b); i += aes.BlockSize {
+		block.Encrypt(b[i:], b[i:])
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func pkcs7(b []byte) []byte {
+	n := aes.BlockSize - len(b)%aes.BlockSize
+	return append(b, bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func TestDecryptRefundNotify(t *testing.T) {
+	w := New(nil)
+	plain := []byte("<root><out_trade_no><![CDATA[o1]]></out_trade_no><out_refund_no><![CDATA[r1]]></out_refund_no>" +
+		"<refund_fee>100</refund_fee><refund_status><![CDATA[SUCCESS]]></refund_status></root>")
+	info, err := w.DecryptRefundNotify(&RefundNotifyReq{ReqInfo: reqInfo(t, plain, "secret", pkcs7)}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.OutTradeNo != "o1" || info.OutRefundNo != "r1" || info.RefundFee != 100 || info.RefundStatus != "SUCCESS" {
+		t.Errorf("info = %+v", info)
+	}
+
+	tests := []struct {
+		name    string
+		reqInfo string
+	}{
+		{"wrong secret", reqInfo(t, plain, "other", pkcs7)},
+		{"zero padding", reqInfo(t, plain, "secret", func(b []byte) []byte {
+			b = pkcs7(b)
+			b[len(b)-1] = 0
+			return b
+		})},
+		{"padding longer than a block", reqInfo(t, plain, "secret", func(b []byte) []byte {
+			b = pkcs7(b)
+			b[len(b)-1] = aes.BlockSize + 1
+			return b
+		})},
+		{"not a block multiple", reqInfo(t, plain, "secret", func(b []byte) []byte { return append(pkcs7(b), 1, 2, 3) })},
+		{"empty", ""},
+		{"not base64", "!!"},
+	}
+	for _, tt := range tests {
+		if _, err := w.DecryptRefundNotify(&RefundNotifyReq{ReqInfo: tt.reqInfo}, "secret"); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package wechat
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+const (
+	apiHost       = "https://api.mch.weixin.qq.com/"
+	sandboxHost   = "https://api.mch.weixin.qq.com/sandboxnew/"
+	signKeyURL    = "https://api.mch.weixin.qq.com/sandboxnew/pay/getsignkey"
+	sandboxRefund = "https://api.mch.weixin.qq.com/sandboxnew/pay/refund"
+)
+
+// Sandbox makes the Wechat talk to the sandbox as merchant mchID, every
+// endpoint is rewritten to its /sandboxnew path. Sign, Verify and the other
+// helpers are still given the API secret, and swap in its sandbox key.
+func Sandbox(mchID string) Option {
+	return func(w *Wechat) {
+		w.sandbox = mchID
+		w.keys = map[string]string{}
+	}
+}
+
+func (w *Wechat) url(u string) string {
+	if w.sandbox == "" {
+		return u
+	}
+	if u == refundURL {
+		return sandboxRefund
+	}
+	return strings.Replace(u, apiHost, sandboxHost, 1)
+}
+
+type signKeyReq struct {
+	XMLName  xml.Name `xml:"xml"`
+	MchID    string   `xml:"mch_id" structs:"mch_id"`
+	NonceStr string   `xml:"nonce_str" structs:"nonce_str"`
+	Sign     string   `xml:"sign" structs:"sign"`
+}
+
+type signKeyResp struct {
+	Resp
+	MchID          string `xml:"mch_id"`
+	SandboxSignKey string `xml:"sandbox_signkey"`
+}
+
+// SandboxKey returns the sandbox key of secret, fetching it from
+// getsignkey the first time and caching it. In sandbox mode requests and
+// notifications are signed with it instead of secret.
+func (w *Wechat) SandboxKey(secret string) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if k, ok := w.keys[secret]; ok {
+		return k, nil
+	}
+	r := &signKeyReq{MchID: w.sandbox, NonceStr: nonceStr()}
+	r.Sign = sign(r, secret)
+	req, err := w.client.NewRequest("POST", signKeyURL, r)
+	if err != nil {
+		return "", err
+	}
+	res := new(signKeyResp)
+	if _, err := w.client.Do(req, res); err != nil {
+		return "", err
+	}
+	if res.ReturnCode != CodeSuccess || res.SandboxSignKey == "" {
+		return "", fmt.Errorf("wechat: getsignkey failed: %s", res.ReturnMsg)
+	}
+	w.keys[secret] = res.SandboxSignKey
+	return res.SandboxSignKey, nil
+}
+
+// signKey returns the key signing with secret, which is the sandbox key in
+// sandbox mode.
+func (w *Wechat) signKey(secret string) (string, error) {
+	if w.sandbox == "" {
+		return secret, nil
+	}
+	return w.SandboxKey(secret)
+}
@@ -0,0 +1,56 @@
+package wechat
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestSandboxKey(t *testing.T) {
+	w := New(nil, Sandbox("m1"))
+	w.keys["secret"] = "sandbox"
+
+	r := &signKeyReq{MchID: "m1", NonceStr: "n1"}
+	if got, want := w.Sign(r, "secret"), sign(r, "sandbox"); got != want {
+		t.Errorf("Sign = %s, want the sign of the sandbox key %s", got, want)
+	}
+	f := Fields{"return_code": "SUCCESS", "out_trade_no": "o1"}
+	f["sign"] = signParams(f, "sandbox")
+	if !w.Verify(f, "secret") {
+		t.Error("sign of the sandbox key rejected")
+	}
+	f["sign"] = signParams(f, "secret")
+	if w.Verify(f, "secret") {
+		t.Error("sign of the API secret accepted in sandbox mode")
+	}
+
+	plain := []byte("<root><out_refund_no>r1</out_refund_no></root>")
+	info, err := w.DecryptRefundNotify(&RefundNotifyReq{ReqInfo: reqInfo(t, plain, "sandbox", pkcs7)}, "secret")
+	if err != nil || info.OutRefundNo != "r1" {
+		t.Errorf("got %+v, %v", info, err)
+	}
+}
+
+func TestSandboxKeyError(t *testing.T) {
+	w := New(&http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("offline")
+	})}, Sandbox("m1"))
+	if s := w.Sign(&signKeyReq{MchID: "m1"}, "secret"); s != "" {
+		t.Errorf("Sign = %s without the sandbox key", s)
+	}
+	f := Fields{"return_code": "SUCCESS"}
+	f["sign"] = signParams(f, "secret")
+	if w.Verify(f, "secret") {
+		t.Error("verified without the sandbox key")
+	}
+	if _, err := w.DecryptRefundNotify(&RefundNotifyReq{ReqInfo: reqInfo(t, []byte("<root/>"), "secret", pkcs7)}, "secret"); err == nil {
+		t.Error("decrypted without the sandbox key")
+	}
+	if _, err := w.SandboxKey("secret"); err == nil {
+		t.Error("SandboxKey reports no error")
+	}
+}
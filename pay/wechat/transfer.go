@@ -0,0 +1,194 @@
+package wechat
+
+import "encoding/xml"
+
+// Transfers, red packets and their queries all require the Wechat to be
+// created with a client returned by TLSClient. Their requests name the
+// merchant fields differently from Req, so they don't embed it.
+
+// transfer name checking options
+const (
+	NoCheck    = "NO_CHECK"
+	ForceCheck = "FORCE_CHECK"
+)
+
+// AmtAllRand randomizes amounts of a group red packet.
+const AmtAllRand = "ALL_RAND"
+
+// BillTypeMCHT queries a red packet by mch_billno.
+const BillTypeMCHT = "MCHT"
+
+// TransferReq pays to the balance of a user.
+type TransferReq struct {
+	XMLName        xml.Name `xml:"xml" json:"-"`
+	MchAppID       string   `xml:"mch_appid" structs:"mch_appid"`
+	MchID          string   `xml:"mchid" structs:"mchid"`
+	DeviceInfo     string   `xml:"device_info" structs:"device_info"`
+	NonceStr       string   `xml:"nonce_str" structs:"nonce_str"`
+	Sign           string   `xml:"sign" structs:"sign"`
+	PartnerTradeNo string   `xml:"partner_trade_no" structs:"partner_trade_no"`
+	OpenID         string   `xml:"openid" structs:"openid"`
+	CheckName      string   `xml:"check_name" structs:"check_name"`
+	ReUserName     string   `xml:"re_user_name" structs:"re_user_name"`
+	Amount         int      `xml:"amount" structs:"amount"`
+	Desc           string   `xml:"desc" structs:"desc"`
+	SpbillCreateIP string   `xml:"spbill_create_ip" structs:"spbill_create_ip"`
+}
+
+// TransferResp ...
+type TransferResp struct {
+	Resp
+	MchAppID       string `structs:"mch_appid" xml:"mch_appid" json:"mchAppId"`
+	MchID          string `structs:"mchid" xml:"mchid" json:"mchId"`
+	DeviceInfo     string `structs:"device_info" xml:"device_info" json:"deviceInfo"`
+	NonceStr       string `structs:"nonce_str" xml:"nonce_str" json:"nonceStr"`
+	PartnerTradeNo string `structs:"partner_trade_no" xml:"partner_trade_no" json:"partnerTradeNo"`
+	PaymentNo      string `structs:"payment_no" xml:"payment_no" json:"paymentNo"`
+	PaymentTime    string `structs:"payment_time" xml:"payment_time" json:"paymentTime"`
+}
+
+// Transfer ...
+func (w *Wechat) Transfer(r *TransferReq) (*TransferResp, error) {
+	res := new(TransferResp)
+	if err := w.post(transferURL, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// TransferInfoReq ...
+type TransferInfoReq struct {
+	XMLName        xml.Name `xml:"xml" json:"-"`
+	NonceStr       string   `xml:"nonce_str" structs:"nonce_str"`
+	Sign           string   `xml:"sign" structs:"sign"`
+	PartnerTradeNo string   `xml:"partner_trade_no" structs:"partner_trade_no"`
+	MchID          string   `xml:"mch_id" structs:"mch_id"`
+	AppID          string   `xml:"appid" structs:"appid"`
+}
+
+// TransferInfoResp ...
+type TransferInfoResp struct {
+	Resp
+	PartnerTradeNo string `structs:"partner_trade_no" xml:"partner_trade_no" json:"partnerTradeNo"`
+	AppID          string `structs:"appid" xml:"appid" json:"appId"`
+	MchID          string `structs:"mch_id" xml:"mch_id" json:"mchId"`
+	DetailID       string `structs:"detail_id" xml:"detail_id" json:"detailId"`
+	Status         string `structs:"status" xml:"status" json:"status"`
+	Reason         string `structs:"reason" xml:"reason" json:"reason"`
+	OpenID         string `structs:"openid" xml:"openid" json:"openId"`
+	TransferName   string `structs:"transfer_name" xml:"transfer_name" json:"transferName"`
+	PaymentAmount  int    `structs:"payment_amount" xml:"payment_amount" json:"paymentAmount"`
+	TransferTime   string `structs:"transfer_time" xml:"transfer_time" json:"transferTime"`
+	PaymentTime    string `structs:"payment_time" xml:"payment_time" json:"paymentTime"`
+	Desc           string `structs:"desc" xml:"desc" json:"desc"`
+}
+
+// TransferInfo queries a transfer by partner_trade_no.
+func (w *Wechat) TransferInfo(r *TransferInfoReq) (*TransferInfoResp, error) {
+	res := new(TransferInfoResp)
+	if err := w.post(transferQryURL, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RedpackReq is used by both SendRedpack and SendGroupRedpack, AmtType is
+// for group red packets only while ClientIP is for normal ones only.
+type RedpackReq struct {
+	XMLName     xml.Name `xml:"xml" json:"-"`
+	NonceStr    string   `xml:"nonce_str" structs:"nonce_str"`
+	Sign        string   `xml:"sign" structs:"sign"`
+	MchBillNo   string   `xml:"mch_billno" structs:"mch_billno"`
+	MchID       string   `xml:"mch_id" structs:"mch_id"`
+	WxAppID     string   `xml:"wxappid" structs:"wxappid"`
+	SendName    string   `xml:"send_name" structs:"send_name"`
+	ReOpenID    string   `xml:"re_openid" structs:"re_openid"`
+	TotalAmount int      `xml:"total_amount" structs:"total_amount"`
+	TotalNum    int      `xml:"total_num" structs:"total_num"`
+	AmtType     string   `xml:"amt_type" structs:"amt_type"`
+	Wishing     string   `xml:"wishing" structs:"wishing"`
+	ClientIP    string   `xml:"client_ip" structs:"client_ip"`
+	ActName     string   `xml:"act_name" structs:"act_name"`
+	Remark      string   `xml:"remark" structs:"remark"`
+	SceneID     string   `xml:"scene_id" structs:"scene_id"`
+	RiskInfo    string   `xml:"risk_info" structs:"risk_info"`
+}
+
+// RedpackResp ...
+type RedpackResp struct {
+	Resp
+	MchBillNo   string `structs:"mch_billno" xml:"mch_billno" json:"mchBillNo"`
+	MchID       string `structs:"mch_id" xml:"mch_id" json:"mchId"`
+	WxAppID     string `structs:"wxappid" xml:"wxappid" json:"wxAppId"`
+	ReOpenID    string `structs:"re_openid" xml:"re_openid" json:"reOpenId"`
+	TotalAmount int    `structs:"total_amount" xml:"total_amount" json:"totalAmount"`
+	SendListID  string `structs:"send_listid" xml:"send_listid" json:"sendListId"`
+}
+
+// SendRedpack ...
+func (w *Wechat) SendRedpack(r *RedpackReq) (*RedpackResp, error) {
+	res := new(RedpackResp)
+	if err := w.post(redpackURL, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SendGroupRedpack sends a fission red packet, r.AmtType must be
+// AmtAllRand.
+func (w *Wechat) SendGroupRedpack(r *RedpackReq) (*RedpackResp, error) {
+	res := new(RedpackResp)
+	if err := w.post(groupRedpackURL, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RedpackInfoReq ...
+type RedpackInfoReq struct {
+	XMLName   xml.Name `xml:"xml" json:"-"`
+	NonceStr  string   `xml:"nonce_str" structs:"nonce_str"`
+	Sign      string   `xml:"sign" structs:"sign"`
+	MchBillNo string   `xml:"mch_billno" structs:"mch_billno"`
+	MchID     string   `xml:"mch_id" structs:"mch_id"`
+	AppID     string   `xml:"appid" structs:"appid"`
+	BillType  string   `xml:"bill_type" structs:"bill_type"`
+}
+
+// RedpackRecord is a red packet received by a user.
+type RedpackRecord struct {
+	OpenID  string `structs:"openid" xml:"openid" json:"openId"`
+	Amount  int    `structs:"amount" xml:"amount" json:"amount"`
+	RcvTime string `structs:"rcv_time" xml:"rcv_time" json:"rcvTime"`
+}
+
+// RedpackInfoResp ...
+type RedpackInfoResp struct {
+	Resp
+	MchBillNo    string          `structs:"mch_billno" xml:"mch_billno" json:"mchBillNo"`
+	MchID        string          `structs:"mch_id" xml:"mch_id" json:"mchId"`
+	DetailID     string          `structs:"detail_id" xml:"detail_id" json:"detailId"`
+	Status       string          `structs:"status" xml:"status" json:"status"`
+	SendType     string          `structs:"send_type" xml:"send_type" json:"sendType"`
+	HbType       string          `structs:"hb_type" xml:"hb_type" json:"hbType"`
+	TotalNum     int             `structs:"total_num" xml:"total_num" json:"totalNum"`
+	TotalAmount  int             `structs:"total_amount" xml:"total_amount" json:"totalAmount"`
+	Reason       string          `structs:"reason" xml:"reason" json:"reason"`
+	SendTime     string          `structs:"send_time" xml:"send_time" json:"sendTime"`
+	RefundTime   string          `structs:"refund_time" xml:"refund_time" json:"refundTime"`
+	RefundAmount int             `structs:"refund_amount" xml:"refund_amount" json:"refundAmount"`
+	Wishing      string          `structs:"wishing" xml:"wishing" json:"wishing"`
+	Remark       string          `structs:"remark" xml:"remark" json:"remark"`
+	ActName      string          `structs:"act_name" xml:"act_name" json:"actName"`
+	HbList       []RedpackRecord `structs:"hblist" xml:"hblist>hbinfo" json:"hbList"`
+}
+
+// RedpackInfo queries a red packet by mch_billno, r.BillType must be
+// BillTypeMCHT.
+func (w *Wechat) RedpackInfo(r *RedpackInfoReq) (*RedpackInfoResp, error) {
+	res := new(RedpackInfoResp)
+	if err := w.post(redpackQryURL, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
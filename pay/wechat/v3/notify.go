@@ -0,0 +1,77 @@
+package v3
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+// notification event types
+const (
+	EventTransactionSuccess = "TRANSACTION.SUCCESS"
+	EventRefundSuccess      = "REFUND.SUCCESS"
+	EventRefundAbnormal     = "REFUND.ABNORMAL"
+	EventRefundClosed       = "REFUND.CLOSED"
+)
+
+// Notify ...
+type Notify struct {
+	ID           string   `json:"id"`
+	CreateTime   string   `json:"create_time"`
+	EventType    string   `json:"event_type"`
+	ResourceType string   `json:"resource_type"`
+	Summary      string   `json:"summary"`
+	Resource     Resource `json:"resource"`
+}
+
+// NotifyResp is the body answering a notification.
+type NotifyResp struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// RefundNotify is the decrypted resource of refund notifications.
+type RefundNotify struct {
+	MchID               string `json:"mchid"`
+	OutTradeNo          string `json:"out_trade_no"`
+	TransactionID       string `json:"transaction_id"`
+	OutRefundNo         string `json:"out_refund_no"`
+	RefundID            string `json:"refund_id"`
+	RefundStatus        string `json:"refund_status"`
+	SuccessTime         string `json:"success_time"`
+	UserReceivedAccount string `json:"user_received_account"`
+	Amount              struct {
+		Total       int `json:"total"`
+		Refund      int `json:"refund"`
+		PayerTotal  int `json:"payer_total"`
+		PayerRefund int `json:"payer_refund"`
+	} `json:"amount"`
+}
+
+// ParseNotify verifies the signature of a notification request and
+// decodes it, its resource can be decoded by DecryptNotify.
+func (w *Wechat) ParseNotify(req *http.Request) (*Notify, error) {
+	defer req.Body.Close()
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Verify(req.Header, b); err != nil {
+		return nil, err
+	}
+	n := new(Notify)
+	if err := json.Unmarshal(b, n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// DecryptNotify decrypts the resource of n into v, which is a *Transaction
+// for TRANSACTION.* events and a *RefundNotify for REFUND.* events.
+func (w *Wechat) DecryptNotify(n *Notify, v interface{}) error {
+	b, err := w.Decrypt(&n.Resource)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,190 @@
+package v3
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Amount ...
+type Amount struct {
+	Total    int    `json:"total"`
+	Currency string `json:"currency,omitempty"`
+}
+
+// Payer ...
+type Payer struct {
+	OpenID string `json:"openid"`
+}
+
+// H5Info ...
+type H5Info struct {
+	Type    string `json:"type"`
+	AppName string `json:"app_name,omitempty"`
+	AppURL  string `json:"app_url,omitempty"`
+}
+
+// SceneInfo is required by H5 orders.
+type SceneInfo struct {
+	PayerClientIP string  `json:"payer_client_ip"`
+	DeviceID      string  `json:"device_id,omitempty"`
+	H5Info        *H5Info `json:"h5_info,omitempty"`
+}
+
+// OrderReq ...
+type OrderReq struct {
+	AppID       string     `json:"appid"`
+	MchID       string     `json:"mchid"`
+	Description string     `json:"description"`
+	OutTradeNo  string     `json:"out_trade_no"`
+	TimeExpire  string     `json:"time_expire,omitempty"`
+	Attach      string     `json:"attach,omitempty"`
+	NotifyURL   string     `json:"notify_url"`
+	GoodsTag    string     `json:"goods_tag,omitempty"`
+	Amount      Amount     `json:"amount"`
+	Payer       *Payer     `json:"payer,omitempty"`
+	SceneInfo   *SceneInfo `json:"scene_info,omitempty"`
+}
+
+// OrderResp carries the field matching the order type.
+type OrderResp struct {
+	PrepayID string `json:"prepay_id,omitempty"`
+	CodeURL  string `json:"code_url,omitempty"`
+	H5URL    string `json:"h5_url,omitempty"`
+}
+
+func (w *Wechat) order(kind string, r *OrderReq) (*OrderResp, error) {
+	if r.MchID == "" {
+		r.MchID = w.mchID
+	}
+	res := new(OrderResp)
+	if err := w.do("POST", "/v3/pay/transactions/"+kind, r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// JSAPIOrder places a JSAPI or mini program order, r.Payer is required.
+func (w *Wechat) JSAPIOrder(r *OrderReq) (*OrderResp, error) {
+	return w.order("jsapi", r)
+}
+
+// AppOrder ...
+func (w *Wechat) AppOrder(r *OrderReq) (*OrderResp, error) {
+	return w.order("app", r)
+}
+
+// NativeOrder returns the code_url to be rendered as a QR code.
+func (w *Wechat) NativeOrder(r *OrderReq) (*OrderResp, error) {
+	return w.order("native", r)
+}
+
+// H5Order returns the h5_url, r.SceneInfo with H5Info is required.
+func (w *Wechat) H5Order(r *OrderReq) (*OrderResp, error) {
+	return w.order("h5", r)
+}
+
+// Transaction ...
+type Transaction struct {
+	AppID          string `json:"appid"`
+	MchID          string `json:"mchid"`
+	OutTradeNo     string `json:"out_trade_no"`
+	TransactionID  string `json:"transaction_id"`
+	TradeType      string `json:"trade_type"`
+	TradeState     string `json:"trade_state"`
+	TradeStateDesc string `json:"trade_state_desc"`
+	BankType       string `json:"bank_type"`
+	Attach         string `json:"attach"`
+	SuccessTime    string `json:"success_time"`
+	Payer          Payer  `json:"payer"`
+	Amount         struct {
+		Total         int    `json:"total"`
+		PayerTotal    int    `json:"payer_total"`
+		Currency      string `json:"currency"`
+		PayerCurrency string `json:"payer_currency"`
+	} `json:"amount"`
+}
+
+// QueryByID queries a transaction by transaction_id.
+func (w *Wechat) QueryByID(transactionID string) (*Transaction, error) {
+	res := new(Transaction)
+	path := "/v3/pay/transactions/id/" + url.PathEscape(transactionID) + "?mchid=" + url.QueryEscape(w.mchID)
+	if err := w.do("GET", path, nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// QueryByOutTradeNo queries a transaction by out_trade_no.
+func (w *Wechat) QueryByOutTradeNo(outTradeNo string) (*Transaction, error) {
+	res := new(Transaction)
+	path := "/v3/pay/transactions/out-trade-no/" + url.PathEscape(outTradeNo) + "?mchid=" + url.QueryEscape(w.mchID)
+	if err := w.do("GET", path, nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Close closes an unpaid order.
+func (w *Wechat) Close(outTradeNo string) error {
+	body := struct {
+		MchID string `json:"mchid"`
+	}{w.mchID}
+	return w.do("POST", "/v3/pay/transactions/out-trade-no/"+url.PathEscape(outTradeNo)+"/close", &body, nil)
+}
+
+// JSReq packages needed params for JSAPI and mini program payment.
+type JSReq struct {
+	AppID     string `json:"appId"`
+	TimeStamp string `json:"timeStamp"`
+	NonceStr  string `json:"nonceStr"`
+	Package   string `json:"package"`
+	SignType  string `json:"signType"`
+	PaySign   string `json:"paySign"`
+}
+
+// JSParams returns the signed JSReq of a JSAPI order.
+func (w *Wechat) JSParams(appID, prepayID string) (*JSReq, error) {
+	j := &JSReq{
+		AppID:     appID,
+		TimeStamp: strconv.FormatInt(time.Now().Unix(), 10),
+		NonceStr:  nonceStr(),
+		Package:   "prepay_id=" + prepayID,
+		SignType:  "RSA",
+	}
+	sig, err := w.sign(j.AppID + "\n" + j.TimeStamp + "\n" + j.NonceStr + "\n" + j.Package + "\n")
+	if err != nil {
+		return nil, err
+	}
+	j.PaySign = sig
+	return j, nil
+}
+
+// AppReq packages needed params for the APP SDK.
+type AppReq struct {
+	AppID     string `json:"appid"`
+	PartnerID string `json:"partnerid"`
+	PrepayID  string `json:"prepayid"`
+	Package   string `json:"package"`
+	NonceStr  string `json:"noncestr"`
+	TimeStamp string `json:"timestamp"`
+	Sign      string `json:"sign"`
+}
+
+// AppParams returns the signed AppReq of an APP order.
+func (w *Wechat) AppParams(appID, prepayID string) (*AppReq, error) {
+	a := &AppReq{
+		AppID:     appID,
+		PartnerID: w.mchID,
+		PrepayID:  prepayID,
+		Package:   "Sign=WXPay",
+		NonceStr:  nonceStr(),
+		TimeStamp: strconv.FormatInt(time.Now().Unix(), 10),
+	}
+	sig, err := w.sign(a.AppID + "\n" + a.TimeStamp + "\n" + a.NonceStr + "\n" + a.PrepayID + "\n")
+	if err != nil {
+		return nil, err
+	}
+	a.Sign = sig
+	return a, nil
+}
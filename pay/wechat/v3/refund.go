@@ -0,0 +1,66 @@
+package v3
+
+import "net/url"
+
+// RefundAmount ...
+type RefundAmount struct {
+	Refund   int    `json:"refund"`
+	Total    int    `json:"total"`
+	Currency string `json:"currency"`
+}
+
+// RefundReq ...
+type RefundReq struct {
+	TransactionID string       `json:"transaction_id,omitempty"`
+	OutTradeNo    string       `json:"out_trade_no,omitempty"`
+	OutRefundNo   string       `json:"out_refund_no"`
+	Reason        string       `json:"reason,omitempty"`
+	NotifyURL     string       `json:"notify_url,omitempty"`
+	FundsAccount  string       `json:"funds_account,omitempty"`
+	Amount        RefundAmount `json:"amount"`
+}
+
+// Refund ...
+type Refund struct {
+	RefundID            string `json:"refund_id"`
+	OutRefundNo         string `json:"out_refund_no"`
+	TransactionID       string `json:"transaction_id"`
+	OutTradeNo          string `json:"out_trade_no"`
+	Channel             string `json:"channel"`
+	UserReceivedAccount string `json:"user_received_account"`
+	SuccessTime         string `json:"success_time"`
+	CreateTime          string `json:"create_time"`
+	Status              string `json:"status"`
+	FundsAccount        string `json:"funds_account"`
+	Amount              struct {
+		Total            int    `json:"total"`
+		Refund           int    `json:"refund"`
+		PayerTotal       int    `json:"payer_total"`
+		PayerRefund      int    `json:"payer_refund"`
+		SettlementRefund int    `json:"settlement_refund"`
+		SettlementTotal  int    `json:"settlement_total"`
+		DiscountRefund   int    `json:"discount_refund"`
+		Currency         string `json:"currency"`
+	} `json:"amount"`
+}
+
+// Refund requests a refund.
+func (w *Wechat) Refund(r *RefundReq) (*Refund, error) {
+	if r.Amount.Currency == "" {
+		r.Amount.Currency = "CNY"
+	}
+	res := new(Refund)
+	if err := w.do("POST", "/v3/refund/domestic/refunds", r, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// QueryRefund queries a refund by out_refund_no.
+func (w *Wechat) QueryRefund(outRefundNo string) (*Refund, error) {
+	res := new(Refund)
+	if err := w.do("GET", "/v3/refund/domestic/refunds/"+url.PathEscape(outRefundNo), nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
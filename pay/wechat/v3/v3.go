@@ -0,0 +1,353 @@
+// Package v3 provides access to the WeChat Pay API v3, which is JSON over
+// HTTPS signed with WECHATPAY2-SHA256-RSA2048.
+package v3
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/douglarek/apikit"
+)
+
+const (
+	baseURL = "https://api.mch.weixin.qq.com"
+	schema  = "WECHATPAY2-SHA256-RSA2048"
+
+	// certificates are refreshed after certTTL to pick up rotated ones.
+	certTTL = 12 * time.Hour
+	// minRefresh limits the refreshes triggered by unknown serials, which
+	// come from unauthenticated notifications.
+	minRefresh = time.Minute
+	// maxSkew is the max allowed clock skew of signed responses.
+	maxSkew = 5 * time.Minute
+)
+
+// Config is the merchant configuration.
+type Config struct {
+	MchID string
+	// SerialNo is the serial number of the merchant API certificate.
+	SerialNo string
+	// PrivateKey is the PEM content of apiclient_key.pem.
+	PrivateKey []byte
+	APIv3Key   string
+	// BaseURL defaults to https://api.mch.weixin.qq.com.
+	BaseURL string
+}
+
+// Wechat ...
+type Wechat struct {
+	client  *apikit.Client
+	mchID   string
+	serial  string
+	key     *rsa.PrivateKey
+	v3Key   []byte
+	baseURL string
+
+	mu      sync.RWMutex
+	certs   map[string]*x509.Certificate
+	fetched time.Time
+	tried   time.Time
+	// refresh collapses concurrent refreshes of Certificate into one.
+	refresh sync.Mutex
+}
+
+// New makes a wechat v3 ...
+func New(httpClient *http.Client, c Config) (*Wechat, error) {
+	key, err := parsePrivateKey(c.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.APIv3Key) != 32 {
+		return nil, errors.New("wechat: APIv3 key must be 32 bytes")
+	}
+	if c.BaseURL == "" {
+		c.BaseURL = baseURL
+	}
+	cl := apikit.NewClient(httpClient)
+	cl.SetHeader(apikit.H{
+		"Content-Type": apikit.MediaJSON,
+		"Accept":       "application/json",
+		"User-Agent":   "apikit",
+	})
+	return &Wechat{
+		client:  cl,
+		mchID:   c.MchID,
+		serial:  c.SerialNo,
+		key:     key,
+		v3Key:   []byte(c.APIv3Key),
+		baseURL: c.BaseURL,
+	}, nil
+}
+
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	p, _ := pem.Decode(b)
+	if p == nil {
+		return nil, errors.New("wechat: failed to parse private key PEM")
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(p.Bytes); err == nil {
+		return k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(p.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rk, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("wechat: private key is not RSA")
+	}
+	return rk, nil
+}
+
+// Error is an error response of the API.
+type Error struct {
+	StatusCode int             `json:"-"`
+	Code       string          `json:"code"`
+	Message    string          `json:"message"`
+	Detail     json.RawMessage `json:"detail,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("wechat: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+func nonceStr() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (w *Wechat) sign(msg string) (string, error) {
+	hashed := sha256.Sum256([]byte(msg))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, w.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// Authorization returns the Authorization header of a request, path
+// includes the query string.
+func (w *Wechat) Authorization(method, path string, body []byte) (string, error) {
+	nonce, ts := nonceStr(), strconv.FormatInt(time.Now().Unix(), 10)
+	sig, err := w.sign(method + "\n" + path + "\n" + ts + "\n" + nonce + "\n" + string(body) + "\n")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`%s mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		schema, w.mchID, nonce, sig, ts, w.serial), nil
+}
+
+// send sends a signed request and returns the response with its body, the
+// response signature is not verified.
+func (w *Wechat) send(method, path string, body interface{}) (*http.Response, []byte, error) {
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return nil, nil, err
+		}
+	}
+	req, err := w.client.NewRequest(method, w.baseURL+path, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	auth, err := w.Authorization(method, path, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", auth)
+	var buf bytes.Buffer
+	resp, err := w.client.Do(req, &buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		e := &Error{StatusCode: resp.StatusCode}
+		json.Unmarshal(buf.Bytes(), e)
+		return nil, nil, e
+	}
+	return resp, buf.Bytes(), nil
+}
+
+// do sends a signed request, verifies the response and decodes it into v.
+func (w *Wechat) do(method, path string, body, v interface{}) error {
+	resp, b, err := w.send(method, path, body)
+	if err != nil {
+		return err
+	}
+	if err := w.Verify(resp.Header, b); err != nil {
+		return err
+	}
+	if v != nil && len(b) != 0 {
+		return json.Unmarshal(b, v)
+	}
+	return nil
+}
+
+// Verify verifies the Wechatpay-Signature of a response or notification
+// against the cached platform certificates.
+func (w *Wechat) Verify(h http.Header, body []byte) error {
+	c, err := w.Certificate(h.Get("Wechatpay-Serial"))
+	if err != nil {
+		return err
+	}
+	return verify(c, h, body)
+}
+
+func verify(c *x509.Certificate, h http.Header, body []byte) error {
+	ts := h.Get("Wechatpay-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errors.New("wechat: invalid Wechatpay-Timestamp")
+	}
+	if d := time.Since(time.Unix(sec, 0)); d > maxSkew || d < -maxSkew {
+		return errors.New("wechat: Wechatpay-Timestamp expired")
+	}
+	sig, err := base64.StdEncoding.DecodeString(h.Get("Wechatpay-Signature"))
+	if err != nil {
+		return err
+	}
+	pub, ok := c.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("wechat: platform certificate is not RSA")
+	}
+	hashed := sha256.Sum256([]byte(ts + "\n" + h.Get("Wechatpay-Nonce") + "\n" + string(body) + "\n"))
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], sig)
+}
+
+// Resource is an encrypted resource of notifications and certificates.
+type Resource struct {
+	Algorithm      string `json:"algorithm"`
+	Ciphertext     string `json:"ciphertext"`
+	AssociatedData string `json:"associated_data"`
+	Nonce          string `json:"nonce"`
+	OriginalType   string `json:"original_type,omitempty"`
+}
+
+// Decrypt decrypts an AEAD_AES_256_GCM resource with the APIv3 key.
+func (w *Wechat) Decrypt(r *Resource) ([]byte, error) {
+	if r.Algorithm != "AEAD_AES_256_GCM" {
+		return nil, fmt.Errorf("wechat: unsupported algorithm %s", r.Algorithm)
+	}
+	b, err := base64.StdEncoding.DecodeString(r.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(w.v3Key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(r.Nonce))
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, []byte(r.Nonce), b, []byte(r.AssociatedData))
+}
+
+type certificatesResp struct {
+	Data []struct {
+		SerialNo           string   `json:"serial_no"`
+		EffectiveTime      string   `json:"effective_time"`
+		ExpireTime         string   `json:"expire_time"`
+		EncryptCertificate Resource `json:"encrypt_certificate"`
+	} `json:"data"`
+}
+
+// Certificates downloads the platform certificates and replaces the cache.
+func (w *Wechat) Certificates() (map[string]*x509.Certificate, error) {
+	w.mu.Lock()
+	w.tried = time.Now()
+	w.mu.Unlock()
+	resp, b, err := w.send("GET", "/v3/certificates", nil)
+	if err != nil {
+		return nil, err
+	}
+	var res certificatesResp
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	certs := make(map[string]*x509.Certificate, len(res.Data))
+	for _, d := range res.Data {
+		pt, err := w.Decrypt(&d.EncryptCertificate)
+		if err != nil {
+			return nil, err
+		}
+		p, _ := pem.Decode(pt)
+		if p == nil {
+			return nil, errors.New("wechat: failed to parse platform certificate PEM")
+		}
+		c, err := x509.ParseCertificate(p.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs[d.SerialNo] = c
+	}
+	// the download is signed by one of the certificates it carries.
+	c, ok := certs[resp.Header.Get("Wechatpay-Serial")]
+	if !ok {
+		return nil, errors.New("wechat: certificates response signed by unknown serial")
+	}
+	if err := verify(c, resp.Header, b); err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	w.certs, w.fetched = certs, time.Now()
+	w.mu.Unlock()
+	return certs, nil
+}
+
+// Certificate returns the platform certificate of serial, the cache is
+// refreshed when it is stale or serial is unknown, but at most once per
+// minRefresh.
+func (w *Wechat) Certificate(serial string) (*x509.Certificate, error) {
+	c, ok, fresh, recent := w.cached(serial)
+	if ok && fresh {
+		return c, nil
+	}
+	if !recent {
+		w.refresh.Lock()
+		defer w.refresh.Unlock()
+		// another goroutine may have refreshed while waiting.
+		if c, ok, fresh, recent = w.cached(serial); ok && fresh {
+			return c, nil
+		}
+	}
+	if !recent {
+		certs, err := w.Certificates()
+		if err != nil && !ok {
+			return nil, err
+		}
+		if err == nil {
+			c, ok = certs[serial]
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("wechat: unknown platform certificate %s", serial)
+	}
+	return c, nil
+}
+
+// cached looks serial up in the cache, recent tells whether the last
+// refresh was tried within minRefresh.
+func (w *Wechat) cached(serial string) (c *x509.Certificate, ok, fresh, recent bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	c, ok = w.certs[serial]
+	return c, ok, time.Since(w.fetched) < certTTL, time.Since(w.tried) < minRefresh
+}
@@ -0,0 +1,266 @@
+package v3
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testV3Key = "0123456789abcdef0123456789abcdef"
+
+func testKey(t *testing.T) *rsa.PrivateKey {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func testWechat(t *testing.T, baseURL string) (*Wechat, *rsa.PrivateKey) {
+	k := testKey(t)
+	w, err := New(nil, Config{
+		MchID:      "1900000001",
+		SerialNo:   "MERCHANT",
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}),
+		APIv3Key:   testV3Key,
+		BaseURL:    baseURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, k
+}
+
+// platform is a platform certificate and its key signing responses.
+type platform struct {
+	serial string
+	key    *rsa.PrivateKey
+	cert   *x509.Certificate
+	pem    []byte
+}
+
+func newPlatform(t *testing.T, serial string) *platform {
+	k := testKey(t)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Tenpay.com Root CA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &k.PublicKey, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &platform{serial, k, c, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// sign sets the Wechatpay-* headers of body signed at ts.
+func (p *platform) sign(t *testing.T, h http.Header, body []byte, ts time.Time) {
+	sec, nonce := strconv.FormatInt(ts.Unix(), 10), "n0nce"
+	hashed := sha256.Sum256([]byte(sec + "\n" + nonce + "\n" + string(body) + "\n"))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Set("Wechatpay-Serial", p.serial)
+	h.Set("Wechatpay-Timestamp", sec)
+	h.Set("Wechatpay-Nonce", nonce)
+	h.Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString(sig))
+}
+
+func TestAuthorization(t *testing.T) {
+	w, k := testWechat(t, "")
+	body := []byte(`{"appid":"wx1"}`)
+	auth, err := w.Authorization("POST", "/v3/pay/transactions/jsapi?x=1", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`^WECHATPAY2-SHA256-RSA2048 mchid="1900000001",nonce_str="(\w+)",signature="([^"]+)",timestamp="(\d+)",serial_no="MERCHANT"$`).FindStringSubmatch(auth)
+	if m == nil {
+		t.Fatalf("malformed %s", auth)
+	}
+	nonce, ts := m[1], m[3]
+	sig, err := base64.StdEncoding.DecodeString(m[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := sha256.Sum256([]byte("POST\n/v3/pay/transactions/jsapi?x=1\n" + ts + "\n" + nonce + "\n" + string(body) + "\n"))
+	if err := rsa.VerifyPKCS1v15(&k.PublicKey, crypto.SHA256, hashed[:], sig); err != nil {
+		t.Errorf("signature does not match the signing string: %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	p := newPlatform(t, "PLATFORM")
+	body := []byte(`{"id":"1"}`)
+	tests := []struct {
+		name string
+		body []byte
+		ts   time.Time
+		ok   bool
+	}{
+		{"valid", body, time.Now(), true},
+		{"tampered body", []byte(`{"id":"2"}`), time.Now(), false},
+		{"stale timestamp", body, time.Now().Add(-maxSkew - time.Minute), false},
+		{"future timestamp", body, time.Now().Add(maxSkew + time.Minute), false},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		p.sign(t, h, body, tt.ts)
+		if err := verify(p.cert, h, tt.body); (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
+
+func TestDecrypt(t *testing.T) {
+	// an AES-256-GCM vector of crypto/cipher.
+	key, _ := hex.DecodeString("feffe9928665731c6d6a8f9467308308feffe9928665731c6d6a8f9467308308")
+	nonce, _ := hex.DecodeString("54cc7dc2c37ec006bcc6d1da")
+	ct, _ := hex.DecodeString("d50b9e252b70945d4240d351677eb10f937cdaef6f2822b6a3191654ba41b197")
+	w := &Wechat{v3Key: key}
+	r := &Resource{
+		Algorithm:  "AEAD_AES_256_GCM",
+		Ciphertext: base64.StdEncoding.EncodeToString(ct),
+		Nonce:      string(nonce),
+	}
+	pt, err := w.Decrypt(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "007c5e5b3e59df24a7c355584fc1518d"; hex.EncodeToString(pt) != want {
+		t.Errorf("got %x, want %s", pt, want)
+	}
+
+	r.AssociatedData = "transaction"
+	if _, err := w.Decrypt(r); err == nil {
+		t.Error("wrong associated data accepted")
+	}
+	r.AssociatedData, r.Algorithm = "", "AEAD_AES_128_GCM"
+	if _, err := w.Decrypt(r); err == nil {
+		t.Error("unsupported algorithm accepted")
+	}
+}
+
+// encrypt is the encryption of Decrypt with the test APIv3 key.
+func encrypt(t *testing.T, pt []byte) Resource {
+	block, err := aes.NewCipher([]byte(testV3Key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := "0123456789ab"
+	return Resource{
+		Algorithm:      "AEAD_AES_256_GCM",
+		Ciphertext:     base64.StdEncoding.EncodeToString(gcm.Seal(nil, []byte(nonce), pt, []byte("certificate"))),
+		AssociatedData: "certificate",
+		Nonce:          nonce,
+	}
+}
+
+func TestCertificate(t *testing.T) {
+	p := newPlatform(t, "PLATFORM")
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path != "/v3/certificates" || r.Header.Get("Authorization") == "" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		b, _ := json.Marshal(map[string]interface{}{
+			"data": []interface{}{map[string]interface{}{
+				"serial_no":           p.serial,
+				"encrypt_certificate": encrypt(t, p.pem),
+			}},
+		})
+		p.sign(t, rw.Header(), b, time.Now())
+		rw.Write(b)
+	}))
+	defer srv.Close()
+	w, _ := testWechat(t, srv.URL)
+
+	c, err := w.Certificate(p.serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.Raw, p.cert.Raw) {
+		t.Error("got another certificate")
+	}
+	if _, err := w.Certificate(p.serial); err != nil || atomic.LoadInt32(&hits) != 1 {
+		t.Fatalf("cached lookup: %v, %d hits", err, hits)
+	}
+
+	// unknown serials come from unauthenticated notifications, a burst of
+	// them must not hit the API more than once per minRefresh.
+	unknown := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := w.Certificate("FORGED" + strconv.Itoa(i)); err == nil {
+					t.Error("unknown serial accepted")
+				}
+			}(i)
+		}
+		wg.Wait()
+	}
+	unknown()
+	if atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("refreshed within minRefresh, %d hits", hits)
+	}
+	w.mu.Lock()
+	w.tried = time.Now().Add(-minRefresh)
+	w.mu.Unlock()
+	unknown()
+	if atomic.LoadInt32(&hits) != 2 {
+		t.Errorf("got %d hits after minRefresh, want 2", hits)
+	}
+}
+
+func TestCertificatesUnsigned(t *testing.T) {
+	p := newPlatform(t, "PLATFORM")
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := json.Marshal(map[string]interface{}{
+			"data": []interface{}{map[string]interface{}{
+				"serial_no":           p.serial,
+				"encrypt_certificate": encrypt(t, p.pem),
+			}},
+		})
+		p.sign(t, rw.Header(), append(b, ' '), time.Now())
+		rw.Write(b)
+	}))
+	defer srv.Close()
+	w, _ := testWechat(t, srv.URL)
+	if _, err := w.Certificates(); err == nil {
+		t.Error("badly signed download accepted")
+	}
+	if _, err := w.Certificate(p.serial); err == nil {
+		t.Error("certificate of a badly signed download cached")
+	}
+}
@@ -2,31 +2,85 @@ package wechat
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
 	"encoding/xml"
 	"fmt"
 	"net/http"
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/douglarek/apikit"
 	"github.com/fatih/structs"
+	"golang.org/x/crypto/pkcs12"
 )
 
 const (
-	orderURL = "https://api.mch.weixin.qq.com/pay/unifiedorder"
-	queryURL = "https://api.mch.weixin.qq.com/pay/orderquery"
+	orderURL        = "https://api.mch.weixin.qq.com/pay/unifiedorder"
+	queryURL        = "https://api.mch.weixin.qq.com/pay/orderquery"
+	closeURL        = "https://api.mch.weixin.qq.com/pay/closeorder"
+	refundURL       = "https://api.mch.weixin.qq.com/secapi/pay/refund"
+	refundQueryURL  = "https://api.mch.weixin.qq.com/pay/refundquery"
+	billURL         = "https://api.mch.weixin.qq.com/pay/downloadbill"
+	fundFlowURL     = "https://api.mch.weixin.qq.com/pay/downloadfundflow"
+	micropayURL     = "https://api.mch.weixin.qq.com/pay/micropay"
+	reverseURL      = "https://api.mch.weixin.qq.com/secapi/pay/reverse"
+	transferURL     = "https://api.mch.weixin.qq.com/mmpaymkttransfers/promotion/transfers"
+	transferQryURL  = "https://api.mch.weixin.qq.com/mmpaymkttransfers/gettransferinfo"
+	redpackURL      = "https://api.mch.weixin.qq.com/mmpaymkttransfers/sendredpack"
+	groupRedpackURL = "https://api.mch.weixin.qq.com/mmpaymkttransfers/sendgroupredpack"
+	redpackQryURL   = "https://api.mch.weixin.qq.com/mmpaymkttransfers/gethbinfo"
+)
+
+// sign methods
+const (
+	SignMD5        = "MD5"
+	SignHMACSHA256 = "HMAC-SHA256"
 )
 
 // Wechat ...
 type Wechat struct {
 	client *apikit.Client
+
+	// sandbox is the mch_id of the sandbox, empty in production.
+	sandbox string
+	mu      sync.Mutex
+	keys    map[string]string
 }
 
+// Option configures a Wechat.
+type Option func(*Wechat)
+
 // New makes a wechat ...
-func New(httpClient *http.Client) *Wechat {
+func New(httpClient *http.Client, opts ...Option) *Wechat {
 	c := apikit.NewClient(httpClient)
 	c.SetHeader(apikit.H{"Content-Type": apikit.MediaXML})
-	return &Wechat{client: c}
+	w := &Wechat{client: c}
+	for _, o := range opts {
+		o(w)
+	}
+	return w
+}
+
+// TLSClient returns an http client presenting the merchant certificate,
+// which is required by refund and other secapi calls. p12 is the content
+// of apiclient_cert.p12, whose password defaults to the mch_id.
+func TLSClient(p12 []byte, password string) (*http.Client, error) {
+	key, cert, err := pkcs12.Decode(p12, password)
+	if err != nil {
+		return nil, err
+	}
+	c := tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{Certificates: []tls.Certificate{c}},
+		},
+	}, nil
 }
 
 // Req ...
@@ -41,41 +95,42 @@ type Req struct {
 // OrderReq ...
 type OrderReq struct {
 	Req
-	DeviceInfo     string `xml:"device_info" structs:"device_info"`
-	Body           string `xml:"body" structs:"body"`
-	Detail         string `xml:"detail" structs:"detail"`
-	Attach         string `xml:"attach" structs:"attach"`
-	OutTradeNo     string `xml:"out_trade_no" structs:"out_trade_no"`
-	FeeType        string `xml:"fee_type" structs:"fee_type"`
-	TotalFee       int    `xml:"total_fee" structs:"total_fee"`
-	SpbillCreateIP string `xml:"spbill_create_ip" structs:"spbill_create_ip"`
-	TimeStart      string `xml:"time_start" structs:"time_start"`
-	TimeExpire     string `xml:"time_expire" structs:"time_expire"`
-	GoodsTag       string `xml:"goods_tag" structs:"goods_tag"`
-	NotifyURL      string `xml:"notify_url" structs:"notify_url"`
-	TradeType      string `xml:"trade_type" structs:"trade_type"`
-	ProductID      string `xml:"product_id" structs:"product_id"`
-	LimitPay       string `xml:"limit_pay" structs:"limit_pay"`
-	OpenID         string `xml:"openid" structs:"openid"`
+	DeviceInfo     string    `xml:"device_info" structs:"device_info"`
+	Body           string    `xml:"body" structs:"body"`
+	Detail         string    `xml:"detail" structs:"detail"`
+	Attach         string    `xml:"attach" structs:"attach"`
+	OutTradeNo     string    `xml:"out_trade_no" structs:"out_trade_no"`
+	FeeType        string    `xml:"fee_type" structs:"fee_type"`
+	TotalFee       int       `xml:"total_fee" structs:"total_fee"`
+	SpbillCreateIP string    `xml:"spbill_create_ip" structs:"spbill_create_ip"`
+	TimeStart      string    `xml:"time_start" structs:"time_start"`
+	TimeExpire     string    `xml:"time_expire" structs:"time_expire"`
+	GoodsTag       string    `xml:"goods_tag" structs:"goods_tag"`
+	NotifyURL      string    `xml:"notify_url" structs:"notify_url"`
+	TradeType      TradeType `xml:"trade_type" structs:"trade_type"`
+	ProductID      string    `xml:"product_id" structs:"product_id"`
+	LimitPay       string    `xml:"limit_pay" structs:"limit_pay"`
+	OpenID         string    `xml:"openid" structs:"openid"`
 }
 
 // Resp ...
 type Resp struct {
-	ReturnCode string `structs:"return_code" xml:"return_code" json:"returnCode"`
-	ReturnMsg  string `structs:"return_msg" xml:"return_msg" json:"returnMsg"`
-	ResultCode string `structs:"result_code" xml:"result_code" json:"resultCode"`
-	ErrCode    string `structs:"err_code" xml:"err_code" json:"errCode"`
-	ErrCodeDes string `structs:"err_code_des" xml:"err_code_des" json:"errCodeDes"`
+	ReturnCode Code    `structs:"return_code" xml:"return_code" json:"returnCode"`
+	ReturnMsg  string  `structs:"return_msg" xml:"return_msg" json:"returnMsg"`
+	ResultCode Code    `structs:"result_code" xml:"result_code" json:"resultCode"`
+	ErrCode    ErrCode `structs:"err_code" xml:"err_code" json:"errCode"`
+	ErrCodeDes string  `structs:"err_code_des" xml:"err_code_des" json:"errCodeDes"`
 }
 
 // OrderResp ...
 type OrderResp struct {
 	Resp
 	Req
-	DeviceInfo string `xml:"device_info" structs:"device_info"`
-	TradeType  string `xml:"trade_type"`
-	PrepayID   string `xml:"prepay_id"`
-	CodeURL    string `xml:"code_url"`
+	DeviceInfo string    `xml:"device_info" structs:"device_info"`
+	TradeType  TradeType `xml:"trade_type"`
+	PrepayID   string    `xml:"prepay_id"`
+	CodeURL    string    `xml:"code_url"`
+	MWebURL    string    `xml:"mweb_url"`
 }
 
 // QueryReq ...
@@ -89,23 +144,25 @@ type QueryReq struct {
 type QueryResp struct {
 	Resp
 	Req
-	DeviceInfo     string `structs:"device_info" xml:"device_info" json:"deviceInfo"`
-	OpenID         string `structs:"openid" xml:"openid" json:"openId"`
-	IsSubscribe    string `structs:"is_subscribe" xml:"is_subscribe" json:"isSubscribe"`
-	TradeType      string `structs:"trade_type" xml:"trade_type" json:"tradeType"`
-	TradeState     string `structs:"trade_state" xml:"trade_state" json:"tradeState"`
-	BankType       string `structs:"bank_type" xml:"bank_type" json:"bankType"`
-	TotalFee       string `structs:"total_fee" xml:"total_fee" json:"totalFee"`
-	FeeType        string `structs:"fee_type" xml:"fee_type" json:"feeType"`
-	CashFee        int    `structs:"cash_fee" xml:"cash_fee" json:"cashFee"`
-	CashFeeType    string `structs:"cash_fee_type" xml:"cash_fee_type" json:"cashFeeType"`
-	CouponFee      int    `structs:"coupon_fee" xml:"coupon_fee" json:"couponFee"`
-	CouponCount    int    `structs:"coupon_count" xml:"coupon_count" json:"couponCount"`
-	TransactionID  string `structs:"transaction_id" xml:"transaction_id" json:"transactionId"`
-	OutTradeNo     string `structs:"out_trade_no" xml:"out_trade_no" json:"outTradeNo"`
-	Attach         string `structs:"attach" xml:"attach" json:"attach"`
-	TimeEnd        string `structs:"time_end" xml:"time_end" json:"timeEnd"`
-	TradeStateDesc string `structs:"trade_state_desc" xml:"trade_state_desc" json:"tradeStateDesc"`
+	DeviceInfo     string     `structs:"device_info" xml:"device_info" json:"deviceInfo"`
+	OpenID         string     `structs:"openid" xml:"openid" json:"openId"`
+	IsSubscribe    string     `structs:"is_subscribe" xml:"is_subscribe" json:"isSubscribe"`
+	TradeType      TradeType  `structs:"trade_type" xml:"trade_type" json:"tradeType"`
+	TradeState     TradeState `structs:"trade_state" xml:"trade_state" json:"tradeState"`
+	BankType       string     `structs:"bank_type" xml:"bank_type" json:"bankType"`
+	TotalFee       string     `structs:"total_fee" xml:"total_fee" json:"totalFee"`
+	FeeType        string     `structs:"fee_type" xml:"fee_type" json:"feeType"`
+	CashFee        int        `structs:"cash_fee" xml:"cash_fee" json:"cashFee"`
+	CashFeeType    string     `structs:"cash_fee_type" xml:"cash_fee_type" json:"cashFeeType"`
+	CouponFee      int        `structs:"coupon_fee" xml:"coupon_fee" json:"couponFee"`
+	CouponCount    int        `structs:"coupon_count" xml:"coupon_count" json:"couponCount"`
+	TransactionID  string     `structs:"transaction_id" xml:"transaction_id" json:"transactionId"`
+	OutTradeNo     string     `structs:"out_trade_no" xml:"out_trade_no" json:"outTradeNo"`
+	Attach         string     `structs:"attach" xml:"attach" json:"attach"`
+	TimeEnd        string     `structs:"time_end" xml:"time_end" json:"timeEnd"`
+	TradeStateDesc string     `structs:"trade_state_desc" xml:"trade_state_desc" json:"tradeStateDesc"`
+	Coupons        []Coupon   `structs:"-" xml:"-" json:"coupons"`
+	Fields         Fields     `structs:"-" xml:"-" json:"-"`
 }
 
 // NotifyReq ...
@@ -116,7 +173,7 @@ type NotifyReq struct {
 // NotifyResp ...
 type NotifyResp struct {
 	XMLName    xml.Name `xml:"xml"`
-	ReturnCode string   `xml:"return_code"`
+	ReturnCode Code     `xml:"return_code"`
 	ReturnMsg  string   `xml:"return_msg"`
 }
 
@@ -131,12 +188,28 @@ type AppReq struct {
 	Sign      string `structs:"sign" json:"sign"`
 }
 
-// Sign ...
+// Sign signs s with MD5, or HMAC-SHA256 if its sign_type (signType for
+// JSAPI) says so. In sandbox mode it signs with the sandbox key of secret,
+// and returns an empty sign if the key cannot be fetched, which SandboxKey
+// reports.
 func (w *Wechat) Sign(s interface{}, secret string) string {
-	m := apikit.Params(structs.Map(s))
-	delete(m, "sign")
+	key, err := w.signKey(secret)
+	if err != nil {
+		return ""
+	}
+	return sign(s, key)
+}
+
+func sign(s interface{}, secret string) string {
+	return signParams(apikit.Params(structs.Map(s)), secret)
+}
+
+func signParams(m map[string]string, secret string) string {
 	keys := make([]string, 0, len(m))
-	for k := range m {
+	for k, v := range m {
+		if k == "sign" || v == "" {
+			continue
+		}
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
@@ -147,17 +220,27 @@ func (w *Wechat) Sign(s interface{}, secret string) string {
 		buf.WriteString("&")
 	}
 	buf.WriteString("key=" + secret)
+	if m["sign_type"] == SignHMACSHA256 || m["signType"] == SignHMACSHA256 {
+		h := hmac.New(sha256.New, []byte(secret))
+		h.Write(buf.Bytes())
+		return fmt.Sprintf("%X", h.Sum(nil))
+	}
 	return fmt.Sprintf("%X", md5.Sum(buf.Bytes()))
 }
 
-// Order ...
-func (w *Wechat) Order(r *OrderReq) (*OrderResp, error) {
-	req, err := w.client.NewRequest("POST", orderURL, r)
+func (w *Wechat) post(u string, r, v interface{}) error {
+	req, err := w.client.NewRequest("POST", w.url(u), r)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	_, err = w.client.Do(req, v)
+	return err
+}
+
+// Order ...
+func (w *Wechat) Order(r *OrderReq) (*OrderResp, error) {
 	res := new(OrderResp)
-	if _, err := w.client.Do(req, res); err != nil {
+	if err := w.post(orderURL, r, res); err != nil {
 		return nil, err
 	}
 	return res, nil
@@ -165,12 +248,29 @@ func (w *Wechat) Order(r *OrderReq) (*OrderResp, error) {
 
 // Query ...
 func (w *Wechat) Query(r *QueryReq) (*QueryResp, error) {
-	req, err := w.client.NewRequest("POST", queryURL, r)
-	if err != nil {
+	res := new(QueryResp)
+	if err := w.post(queryURL, r, res); err != nil {
 		return nil, err
 	}
-	res := new(QueryResp)
-	if _, err := w.client.Do(req, res); err != nil {
+	return res, nil
+}
+
+// CloseReq ...
+type CloseReq struct {
+	Req
+	OutTradeNo string `xml:"out_trade_no" structs:"out_trade_no"`
+}
+
+// CloseResp ...
+type CloseResp struct {
+	Resp
+	Req
+}
+
+// Close closes an unpaid order.
+func (w *Wechat) Close(r *CloseReq) (*CloseResp, error) {
+	res := new(CloseResp)
+	if err := w.post(closeURL, r, res); err != nil {
 		return nil, err
 	}
 	return res, nil